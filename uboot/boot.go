@@ -2,10 +2,13 @@ package uboot
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"uw/ulog"
@@ -24,34 +27,81 @@ const ubootLogo = `
   ░      ░          ░ ░      ░ ░                													   
 `
 
-var defaultDaemonRestartAfter = 500 * time.Millisecond // 默认守护模块重启时间间隔
+var (
+	defaultDaemonRestartAfter = 500 * time.Millisecond                     // 默认守护模块重启时间间隔
+	defaultShutdownTimeout    = 30 * time.Second                           // 默认关闭总超时时间
+	defaultStopTimeout        = 10 * time.Second                           // 默认单个模块停止超时时间
+	defaultShutdownSignals    = []os.Signal{os.Interrupt, syscall.SIGTERM} // 默认监听的关闭信号
+)
 
 type Printf func(format string, args ...interface{})
 
 type ContextWithCancel struct {
 	context.Context
 	Cancel context.CancelFunc
+	cause  context.CancelCauseFunc
 }
 
 func newContextWithCancel() *ContextWithCancel {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cause := context.WithCancelCause(context.Background())
 	return &ContextWithCancel{
 		Context: ctx,
-		Cancel:  cancel,
+		Cancel:  func() { cause(nil) },
+		cause:   cause,
+	}
+}
+
+// 模块失败, 等待它的依赖模块会收到错误
+func (cwc *ContextWithCancel) fail(e error) {
+	cwc.cause(e)
+}
+
+// 模块失败的错误, 成功完成或未完成时为空
+func (cwc *ContextWithCancel) Failed() error {
+	if e := context.Cause(cwc.Context); e != nil && !errors.Is(e, context.Canceled) {
+		return e
 	}
+
+	return nil
 }
 
 type Boot struct {
 	bootTimeout        time.Duration                          // 启动超时时间
-	daemonRestartAfter time.Duration                          // 守护模块重启时间间隔
+	daemonRestartAfter time.Duration                          // 守护模块默认重启时间间隔
 	printf             Printf                                 // 打印函数
+	customPrintf       bool                                   // 是否通过 SetPrintf 设置了自定义打印函数
 	frontUint          []*UintAgent                           // 预启动模块
 	backgroundUint     []*UintAgent                           // 后台模块
 	normalUint         []*UintAgent                           // 默认模块
 	daemonUint         []*UintAgent                           // 守护模块
 	afterUint          []*UintAgent                           // 延后启动模块
+	cronUint           []*UintAgent                           // 定时模块
 	lock               *sync.Mutex                            // 用于锁定 Boot 对象的 Start，防止重复启动, 类似 once 的作用
 	require            *umap.Hmap[string, *ContextWithCancel] // 用于模块间的依赖
+	planLock           *sync.RWMutex                          // 启动计划锁, 防止排序时读取模块列表
+	ctx                *ContextWithCancel                     // 所有模块上下文的根, 关闭时取消
+	shutdownTimeout    time.Duration                          // 关闭总超时时间
+	stopTimeout        time.Duration                          // 单个模块停止超时时间
+	signals            []os.Signal                            // 触发关闭的信号
+	shutdownOnce       *sync.Once                             // 保证关闭流程只执行一次
+	shutdownDone       chan struct{}                          // 关闭流程完成
+	shutdownErr        error                                  // 关闭流程错误
+	ready              atomic.Bool                            // 所有默认模块是否已经完成
+	events             *EventBus                              // 事件总线, 关闭时排空
+	eventsLock         *sync.Mutex                            // 事件总线创建锁
+	clock              Clock                                  // 时钟
+	returnError        atomic.Bool                            // 模块失败时返回错误而不是 panic
+	failLock           sync.Mutex                             // 失败错误锁
+	failErr            error                                  // 第一个导致失败的错误
+	timing             *bootTiming                            // 启动耗时记录
+	logger             *ulog.Logger                           // 模块上下文日志的基础日志, 为空时使用 ulog.GlobalLogger
+
+	commands    []*command // 子命令
+	globalFlags any        // 全局参数结构体指针
+	output      io.Writer  // logo 和帮助信息输出
+	program     string     // 程序名称
+	commandName string     // 选中的子命令
+	args        []string   // 剩余的位置参数
 
 	allowNameRepeat bool // 允许模块名重复
 
@@ -67,11 +117,23 @@ func NewBoot() *Boot {
 		normalUint:         []*UintAgent{},
 		daemonUint:         []*UintAgent{},
 		afterUint:          []*UintAgent{},
+		cronUint:           []*UintAgent{},
 		lock:               &sync.Mutex{},
+		planLock:           &sync.RWMutex{},
 		require:            umap.NewHmap[string, *ContextWithCancel](),
+		ctx:                newContextWithCancel(),
+		shutdownTimeout:    defaultShutdownTimeout,
+		stopTimeout:        defaultStopTimeout,
+		signals:            defaultShutdownSignals,
+		shutdownOnce:       &sync.Once{},
+		shutdownDone:       make(chan struct{}),
+		eventsLock:         &sync.Mutex{},
+		clock:              SystemClock,
+		timing:             &bootTiming{},
 	}
 
 	b.SetPrintf(ulog.Printf)
+	b.customPrintf = false
 	return b
 }
 
@@ -80,7 +142,9 @@ func (b *Boot) SetLogo(s string) *Boot {
 	return b
 }
 
+// 设置打印函数, 未通过 SetLogger 设置日志时模块的 Context.Printf 也使用该函数输出
 func (b *Boot) SetPrintf(l Printf) *Boot {
+	b.customPrintf = true
 	b.printf = func(format string, args ...interface{}) {
 		l(ulog.SetANSI(ulog.ANSI.Bold, "[UBOOT]") + " " + fmt.Sprintf(format, args...))
 	}
@@ -103,6 +167,67 @@ func (b *Boot) DaemonRestartAfter(t time.Duration) *Boot {
 	return b
 }
 
+// 关闭总超时时间, 包含所有模块的停止钩子
+func (b *Boot) ShutdownTimeout(t time.Duration) *Boot {
+	b.shutdownTimeout = t
+	return b
+}
+
+// 单个模块停止钩子的默认超时时间, 可以被 UintAgent.StopTimeout 覆盖
+func (b *Boot) StopTimeout(t time.Duration) *Boot {
+	b.stopTimeout = t
+	return b
+}
+
+// 设置触发关闭的信号, 不传参数则不监听信号
+func (b *Boot) Signals(sig ...os.Signal) *Boot {
+	b.signals = sig
+	return b
+}
+
+// 设置 Boot 的事件总线, 需要在 Events 之前调用
+func (b *Boot) EventBus(opts EventBusOptions) *Boot {
+	b.eventsLock.Lock()
+	defer b.eventsLock.Unlock()
+
+	if b.events != nil {
+		b.printf("event bus already created")
+		panic("uboot: event bus already created")
+	}
+
+	b.events = NewEventBus(opts)
+	return b
+}
+
+// Boot 的事件总线, 未设置时使用默认配置创建
+func (b *Boot) Events() *EventBus {
+	b.eventsLock.Lock()
+	defer b.eventsLock.Unlock()
+
+	if b.events == nil {
+		b.events = NewEventBus(EventBusOptions{})
+	}
+
+	return b.events
+}
+
+// 设置模块上下文日志的基础日志, 需要在 Start 之前调用, 参考 Context.Logger
+// 设置后 Context.Printf 使用该日志输出, 不再使用 SetPrintf 设置的打印函数
+func (b *Boot) SetLogger(l *ulog.Logger) *Boot {
+	b.logger = l
+	return b
+}
+
+// 设置时钟, 需要在 Start 之前调用
+func (b *Boot) SetClock(c Clock) *Boot {
+	b.clock = c
+	return b
+}
+
+func (b *Boot) now() time.Time {
+	return b.clock.Now()
+}
+
 func (b *Boot) AllowNameRepeat() *Boot {
 	b.allowNameRepeat = true
 	return b
@@ -110,6 +235,11 @@ func (b *Boot) AllowNameRepeat() *Boot {
 
 func (b *Boot) Register(uintAgents ...*UintAgent) *Boot {
 	for i := 0; i < len(uintAgents); i++ {
+		if uintAgents[i].singleton != "" && uintAgents[i].utype != UintDaemon {
+			b.printf("register singleton uint must be daemon: %s", uintAgents[i].name)
+			panic("uboot: register singleton uint must be daemon: " + uintAgents[i].name)
+		}
+
 		switch uintAgents[i].utype {
 		case UintFront:
 			b.frontUint = append(b.frontUint, uintAgents[i])
@@ -121,6 +251,13 @@ func (b *Boot) Register(uintAgents ...*UintAgent) *Boot {
 			b.daemonUint = append(b.daemonUint, uintAgents[i])
 		case UintAfter:
 			b.afterUint = append(b.afterUint, uintAgents[i])
+		case UintCron:
+			if e := uintAgents[i].checkSchedule(); e != nil {
+				b.printf("register cron uint schedule error: %s: %s", uintAgents[i].name, e)
+				panic("uboot: register cron uint schedule error: " + uintAgents[i].name + ": " + e.Error())
+			}
+
+			b.cronUint = append(b.cronUint, uintAgents[i])
 		default:
 			b.printf("register uint type error: %s", uintAgents[i].name)
 			panic("uboot: register uint type error: " + uintAgents[i].name)
@@ -131,6 +268,7 @@ func (b *Boot) Register(uintAgents ...*UintAgent) *Boot {
 			panic("uboot: register uint name repeat: " + uintAgents[i].name)
 		}
 
+		uintAgents[i].status.now = b.now
 		b.require.Set(uintAgents[i].name, newContextWithCancel())
 	}
 
@@ -138,18 +276,22 @@ func (b *Boot) Register(uintAgents ...*UintAgent) *Boot {
 }
 
 func (b *Boot) newContext(u *UintAgent) *Context {
-	prefix := ulog.ANSI.Bold + "[" + strings.ToUpper(UintTypeString(u.utype)) +
-		":" + u.name + "]" + ulog.ANSI.Reset + " "
+	return b.newContextWithParent(b.ctx, u)
+}
 
+func (b *Boot) newContextWithParent(parent context.Context, u *UintAgent) *Context {
 	c := &Context{
 		b: b,
 		u: u,
-		printf: func(format string, args ...interface{}) {
-			b.printf(prefix + ulog.ANSI.Grey + fmt.Sprintf(format, args...) + ulog.ANSI.Reset)
-		},
 	}
 
-	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.ctx, c.cancel = context.WithCancel(parent)
+	logger := b.logger
+	if logger == nil {
+		logger = ulog.FromContext(parent)
+	}
+
+	c.ctx = ulog.WithContext(c.ctx, logger.With("uint", u.name))
 
 	return c
 }
@@ -160,34 +302,55 @@ func (b *Boot) Start() bool {
 	}
 
 	if b.customLogo != "" {
-		os.Stdout.WriteString(b.customLogo)
+		io.WriteString(b.writer(), b.customLogo)
 	} else {
-		os.Stdout.WriteString(ubootLogo)
+		io.WriteString(b.writer(), ubootLogo)
 	}
 
+	b.timing.lock.Lock()
+	b.timing.started = b.now()
+	b.timing.lock.Unlock()
+
 	b.printf(ulog.SetANSI(ulog.ANSI.Bold, "uboot start"))
+	if e := b.resolve(); e != nil {
+		b.printf(ulog.SetANSI(ulog.ANSI.Magenta, "resolve uint plan error: %s"), e)
+		b.fail(errors.New("uboot: resolve uint plan error: " + e.Error()))
+		<-b.shutdownDone
+		return true
+	}
+	b.printPlan()
+
 	if b.bootTimeout > 0 {
-		t := time.AfterFunc(b.bootTimeout, func() {
+		t := b.clock.AfterFunc(b.bootTimeout, func() {
 			b.printf(ulog.SetANSI(ulog.ANSI.Magenta, "normal uint start timeout!"))
-			panic("normal uint start timeout!")
+			b.fail(ErrBootTimeout)
 		})
 
 		defer t.Stop()
 	}
 
 	defer func() {
+		if b.stopping() {
+			<-b.shutdownDone
+		}
+
 		b.printf(ulog.SetANSI(ulog.ANSI.Bold, "uboot done"))
 	}()
 
-	if len(b.frontUint) > 0 {
+	if len(b.signals) > 0 {
+		stop := b.notifySignal()
+		defer close(stop)
+	}
+
+	if len(b.frontUint) > 0 && !b.stopping() {
 		b.printf(ulog.SetANSI(ulog.ANSI.Cyan, "start front uint"))
-		for i := 0; i < len(b.frontUint); i++ {
-			b.frontUint[i].start(b.newContext(b.frontUint[i]))
-		}
+		endPhase := b.beginPhase(UintFront, b.frontUint)
+		b.startLayers(b.frontUint)
+		endPhase()
 		b.printf(ulog.SetANSI(ulog.ANSI.Green, "start front uint done"))
 	}
 
-	if len(b.backgroundUint) > 0 {
+	if len(b.backgroundUint) > 0 && !b.stopping() {
 		wg := &sync.WaitGroup{}
 		defer func() {
 			b.printf(ulog.SetANSI(ulog.ANSI.Green, "waiting for all background uint done"))
@@ -196,6 +359,7 @@ func (b *Boot) Start() bool {
 		}()
 
 		b.printf(ulog.SetANSI(ulog.ANSI.Cyan, "create background uint"))
+		b.beginPhase(UintBackground, b.backgroundUint)
 		for i := 0; i < len(b.backgroundUint); i++ {
 			wg.Add(1)
 			go func(i int) {
@@ -206,10 +370,11 @@ func (b *Boot) Start() bool {
 		b.printf(ulog.SetANSI(ulog.ANSI.Green, "create background uint done"))
 	}
 
-	if len(b.normalUint) > 0 {
+	if len(b.normalUint) > 0 && !b.stopping() {
 		wg := &sync.WaitGroup{}
 
 		b.printf(ulog.SetANSI(ulog.ANSI.Cyan, "create normal uint"))
+		endPhase := b.beginPhase(UintNormal, b.normalUint)
 		for i := 0; i < len(b.normalUint); i++ {
 			wg.Add(1)
 			go func(i int) {
@@ -220,51 +385,56 @@ func (b *Boot) Start() bool {
 		b.printf(ulog.SetANSI(ulog.ANSI.Green, "create normal uint done"))
 		b.printf(ulog.SetANSI(ulog.ANSI.Blue, "waiting for all normal uint done"))
 		wg.Wait()
+		endPhase()
 		b.printf(ulog.SetANSI(ulog.ANSI.Green, "all normal uint done"))
 	}
 
+	b.ready.Store(!b.stopping())
+
 	var daemonWaitGroup *sync.WaitGroup
-	if len(b.daemonUint) > 0 {
+	if len(b.daemonUint) > 0 && !b.stopping() {
 		daemonWaitGroup = &sync.WaitGroup{}
 		b.printf(ulog.SetANSI(ulog.ANSI.Cyan, "create daemon uint"))
+		b.beginPhase(UintDaemon, b.daemonUint)
 		for i := 0; i < len(b.daemonUint); i++ {
 			daemonWaitGroup.Add(1)
 			go func(i int) {
 				defer daemonWaitGroup.Done()
-				b.daemonUint[i].recover = false
-
-				for t := true; t; {
-					c := b.newContext(b.daemonUint[i])
-
-					func() {
-						defer func() {
-							r := recover()
-							c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "daemon uint panic: %v"), r)
-
-							t = r != nil
-						}()
-						b.daemonUint[i].start(c)
-					}()
-
-					if t {
-						c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "daemon uint panic, restart after 500ms"))
-						time.Sleep(b.daemonRestartAfter)
-						c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "restart daemon uint"))
-					}
-				}
+				b.runDaemon(b.daemonUint[i])
 			}(i)
 		}
 		b.printf(ulog.SetANSI(ulog.ANSI.Green, "create daemon uint done"))
 	}
 
-	if len(b.afterUint) > 0 {
+	if len(b.afterUint) > 0 && !b.stopping() {
 		b.printf(ulog.SetANSI(ulog.ANSI.Cyan, "start after uint"))
-		for i := 0; i < len(b.afterUint); i++ {
-			b.afterUint[i].start(b.newContext(b.afterUint[i]))
-		}
+		endPhase := b.beginPhase(UintAfter, b.afterUint)
+		b.startLayers(b.afterUint)
+		endPhase()
 		b.printf(ulog.SetANSI(ulog.ANSI.Green, "start after uint done"))
 	}
 
+	b.printTimings()
+
+	if len(b.cronUint) > 0 && !b.stopping() {
+		wg := &sync.WaitGroup{}
+		defer func() {
+			b.printf(ulog.SetANSI(ulog.ANSI.Blue, "waiting for all cron uint done"))
+			wg.Wait()
+			b.printf(ulog.SetANSI(ulog.ANSI.Green, "all cron uint done"))
+		}()
+
+		b.printf(ulog.SetANSI(ulog.ANSI.Cyan, "create cron uint"))
+		for i := 0; i < len(b.cronUint); i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				b.runCron(b.cronUint[i])
+			}(i)
+		}
+		b.printf(ulog.SetANSI(ulog.ANSI.Green, "create cron uint done"))
+	}
+
 	if daemonWaitGroup != nil {
 		b.printf(ulog.SetANSI(ulog.ANSI.Blue, "waiting for all daemon uint done"))
 		daemonWaitGroup.Wait()
@@ -273,3 +443,62 @@ func (b *Boot) Start() bool {
 
 	return true
 }
+
+// 按依赖分层启动模块, 同一层的模块同时启动, 等待一层完成后再启动下一层
+func (b *Boot) startLayers(list []*UintAgent) {
+	for _, layer := range layerUint(list) {
+		if b.stopping() {
+			return
+		}
+
+		wg := &sync.WaitGroup{}
+		for _, u := range layer {
+			wg.Add(1)
+			go func(u *UintAgent) {
+				defer wg.Done()
+				u.start(b.newContext(u))
+			}(u)
+		}
+		wg.Wait()
+	}
+}
+
+// 启动 Boot, 与 Start 相同, 但是模块失败/超时时不会 panic, 而是关闭 Boot 并返回错误
+// @return 第一个导致失败的错误, 重复启动时返回错误
+func (b *Boot) StartE() error {
+	b.returnError.Store(true)
+	if !b.Start() {
+		return errors.New("uboot already started")
+	}
+
+	b.failLock.Lock()
+	defer b.failLock.Unlock()
+
+	return b.failErr
+}
+
+// 模块失败, 默认 panic, 使用 StartE 启动时记录第一个错误并关闭 Boot
+func (b *Boot) fail(e error) {
+	if !b.returnError.Load() {
+		panic(e.Error())
+	}
+
+	b.failLock.Lock()
+	defer b.failLock.Unlock()
+
+	if b.failErr != nil {
+		return
+	}
+
+	b.failErr = e
+	b.ctx.Cancel()
+	go b.Shutdown(context.Background())
+}
+
+func (b *Boot) writer() io.Writer {
+	if b.output == nil {
+		return os.Stdout
+	}
+
+	return b.output
+}
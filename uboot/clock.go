@@ -0,0 +1,50 @@
+package uboot
+
+import "time"
+
+// 时钟, 用于启动超时/模块超时/守护模块重启/定时模块计划, 测试时可以替换为假时钟
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// 定时器, 与 time.Timer 相同, 通道改为方法
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// 系统时钟
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return &systemTimer{t: time.NewTimer(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return &systemTimer{t: time.AfterFunc(d, f)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (st *systemTimer) C() <-chan time.Time {
+	return st.t.C
+}
+
+func (st *systemTimer) Stop() bool {
+	return st.t.Stop()
+}
+
+func (st *systemTimer) Reset(d time.Duration) bool {
+	return st.t.Reset(d)
+}
@@ -0,0 +1,390 @@
+package uboot
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrHelp            = errors.New("help requested")
+	ErrCommandRequired = errors.New("command required")
+)
+
+// 子命令
+type command struct {
+	path  string // 命令路径, 以空格分隔, 例如 "migrate up"
+	usage string // 帮助信息
+	flags any    // 参数结构体指针
+}
+
+// 注册子命令
+// @param path 命令路径, 以空格分隔, 例如 "migrate up", 空字符串为不带子命令时的默认命令
+// @param usage 帮助信息
+// @param flags 参数结构体指针 (可选), 使用 flag/usage/env/default 标签绑定
+func (b *Boot) Command(path, usage string, flags ...any) *Boot {
+	path = strings.Join(strings.Fields(path), " ")
+
+	for _, cmd := range b.commands {
+		if cmd.path == path {
+			b.printf("register command repeat: %s", path)
+			panic("uboot: register command repeat: " + path)
+		}
+	}
+
+	cmd := &command{path: path, usage: usage}
+	if len(flags) > 0 {
+		cmd.flags = flags[0]
+	}
+
+	b.commands = append(b.commands, cmd)
+	return b
+}
+
+// 全局参数, 所有子命令都可以使用
+// @param flags 参数结构体指针, 使用 flag/usage/env/default 标签绑定
+func (b *Boot) Flags(flags any) *Boot {
+	b.globalFlags = flags
+	return b
+}
+
+// 设置 logo 和帮助信息的输出, 默认 os.Stdout
+func (b *Boot) SetOutput(w io.Writer) *Boot {
+	b.output = w
+	return b
+}
+
+// 选中的子命令路径
+func (b *Boot) CommandName() string {
+	return b.commandName
+}
+
+// 参数解析后剩余的位置参数
+func (b *Boot) Args() []string {
+	return b.args
+}
+
+// 模块所属的子命令, 未设置时所有子命令都会运行该模块
+func (u *UintAgent) Commands(paths ...string) *UintAgent {
+	for _, p := range paths {
+		u.commands = append(u.commands, strings.Join(strings.Fields(p), " "))
+	}
+
+	return u
+}
+
+// 根据命令行参数选择子命令并启动
+// @description 子命令必须在参数之前, 例如 app migrate up -steps 3,
+// -h/--help 会输出自动生成的帮助信息并返回 ErrHelp, 没有注册子命令时直接启动
+// @param args 命令行参数, 通常为 os.Args
+// @return 参数错误, 启动失败 (与 StartE 相同, 模块失败时返回错误)
+func (b *Boot) Run(args []string) error {
+	if len(args) > 0 {
+		b.program, args = filepath.Base(args[0]), args[1:]
+	}
+
+	cmd, rest := b.matchCommand(args)
+
+	// 没有注册子命令时作为默认命令启动, 只解析全局参数
+	if cmd == nil && len(b.commands) < 1 {
+		cmd, rest = &command{}, args
+	}
+
+	if cmd == nil {
+		for _, a := range args {
+			if a == "-h" || a == "-help" || a == "--help" {
+				b.printHelp(nil)
+				return ErrHelp
+			}
+		}
+
+		b.printHelp(nil)
+		if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+			return fmt.Errorf("unknown command: %s", rest[0])
+		}
+
+		return ErrCommandRequired
+	}
+
+	fs := flag.NewFlagSet(cmd.path, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	for _, flags := range []any{b.globalFlags, cmd.flags} {
+		if e := bindFlags(fs, flags); e != nil {
+			return e
+		}
+	}
+
+	if e := fs.Parse(rest); e != nil {
+		if errors.Is(e, flag.ErrHelp) {
+			b.printHelp(cmd)
+			return ErrHelp
+		}
+
+		b.printHelp(cmd)
+		return e
+	}
+
+	b.commandName, b.args = cmd.path, fs.Args()
+	b.selectCommand(cmd.path)
+
+	return b.StartE()
+}
+
+// 匹配最长的子命令路径
+func (b *Boot) matchCommand(args []string) (*command, []string) {
+	var (
+		matched *command
+		rest    = args
+	)
+
+	for _, cmd := range b.commands {
+		words := strings.Fields(cmd.path)
+		if len(words) > len(args) || (matched != nil && len(words) <= len(strings.Fields(matched.path))) {
+			continue
+		}
+
+		ok := true
+		for i := range words {
+			if args[i] != words[i] {
+				ok = false
+				break
+			}
+		}
+
+		if ok {
+			matched, rest = cmd, args[len(words):]
+		}
+	}
+
+	// 默认命令不能有多余的子命令
+	if matched != nil && matched.path == "" && len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		return nil, rest
+	}
+
+	return matched, rest
+}
+
+// 只保留不属于任何子命令或者属于选中子命令的模块, 以及它们直接或间接依赖的模块
+func (b *Boot) selectCommand(path string) {
+	b.planLock.Lock()
+	defer b.planLock.Unlock()
+
+	phases := []*[]*UintAgent{&b.frontUint, &b.backgroundUint, &b.normalUint, &b.daemonUint, &b.afterUint, &b.cronUint}
+
+	keep, kept, units := map[*UintAgent]bool{}, map[string]bool{}, map[string][]*UintAgent{}
+	need := []string{}
+	for _, phase := range phases {
+		for _, u := range *phase {
+			units[u.name] = append(units[u.name], u)
+
+			active := len(u.commands) < 1
+			for _, p := range u.commands {
+				if p == path {
+					active = true
+					break
+				}
+			}
+
+			if active {
+				keep[u], kept[u.name] = true, true
+				need = append(need, u.depends...)
+			}
+		}
+	}
+
+	// 被依赖的模块即使属于其它子命令也要保留, 已经有同名模块保留时不再添加
+	for len(need) > 0 {
+		name := need[len(need)-1]
+		need = need[:len(need)-1]
+		if kept[name] {
+			continue
+		}
+
+		kept[name] = true
+		for _, u := range units[name] {
+			keep[u] = true
+			need = append(need, u.depends...)
+		}
+	}
+
+	for _, phase := range phases {
+		ret := make([]*UintAgent, 0, len(*phase))
+		for _, u := range *phase {
+			if keep[u] {
+				ret = append(ret, u)
+			}
+		}
+		*phase = ret
+	}
+
+	// 允许名称重复时, 被过滤的模块可能与保留的模块同名, 不能删除保留模块的 require
+	for name := range units {
+		if !kept[name] {
+			b.require.Delete(name)
+		}
+	}
+}
+
+// 命令行参数字段
+type flagField struct {
+	name  string
+	usage string
+	env   string
+	def   string
+	value reflect.Value
+}
+
+func flagFields(flags any) ([]*flagField, error) {
+	if flags == nil {
+		return nil, nil
+	}
+
+	v := reflect.ValueOf(flags)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("flags must be a pointer to struct, got %T", flags)
+	}
+
+	v = v.Elem()
+	list := []*flagField{}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		name := f.Tag.Get("flag")
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+
+		list = append(list, &flagField{
+			name:  name,
+			usage: f.Tag.Get("usage"),
+			env:   f.Tag.Get("env"),
+			def:   f.Tag.Get("default"),
+			value: v.Field(i),
+		})
+	}
+
+	return list, nil
+}
+
+// 绑定参数, 优先级: 命令行参数 > 环境变量 > default 标签
+func bindFlags(fs *flag.FlagSet, flags any) error {
+	list, e := flagFields(flags)
+	if e != nil {
+		return e
+	}
+
+	for _, f := range list {
+		if f.def != "" {
+			if e := setFieldString(f.value, f.def); e != nil {
+				return fmt.Errorf("flag %s default: %w", f.name, e)
+			}
+		}
+
+		if f.env != "" {
+			if s, ok := os.LookupEnv(f.env); ok {
+				if e := setFieldString(f.value, s); e != nil {
+					return fmt.Errorf("flag %s env %s: %w", f.name, f.env, e)
+				}
+			}
+		}
+
+		value := f.value
+		set := func(s string) error {
+			return setFieldString(value, s)
+		}
+
+		if f.value.Kind() == reflect.Bool {
+			fs.BoolFunc(f.name, f.usage, set)
+			continue
+		}
+
+		fs.Func(f.name, f.usage, set)
+	}
+
+	return nil
+}
+
+func (b *Boot) printHelp(cmd *command) {
+	w := b.writer()
+
+	program := b.program
+	if program == "" {
+		program = filepath.Base(os.Args[0])
+	}
+
+	if cmd == nil || cmd.path == "" {
+		fmt.Fprintf(w, "Usage: %s <command> [flags]\n", program)
+
+		list := make([]*command, 0, len(b.commands))
+		for _, c := range b.commands {
+			if c.path != "" {
+				list = append(list, c)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].path < list[j].path })
+
+		width := 0
+		for _, c := range list {
+			if len(c.path) > width {
+				width = len(c.path)
+			}
+		}
+
+		fmt.Fprintf(w, "\nCommands:\n")
+		for _, c := range list {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, c.path, c.usage)
+		}
+
+		if cmd != nil {
+			printFlags(w, "Flags", cmd.flags)
+		}
+
+		printFlags(w, "Global Flags", b.globalFlags)
+		fmt.Fprintf(w, "\nUse \"%s <command> --help\" for more information about a command.\n", program)
+		return
+	}
+
+	fmt.Fprintf(w, "Usage: %s %s [flags]\n", program, cmd.path)
+	if cmd.usage != "" {
+		fmt.Fprintf(w, "\n%s\n", cmd.usage)
+	}
+
+	printFlags(w, "Flags", cmd.flags)
+	printFlags(w, "Global Flags", b.globalFlags)
+}
+
+func printFlags(w io.Writer, title string, flags any) {
+	list, _ := flagFields(flags)
+	if len(list) < 1 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, f := range list {
+		name := "-" + f.name
+		if f.value.Kind() != reflect.Bool {
+			if t := f.value.Type().Name(); t != "" {
+				name += " " + strings.ToLower(t)
+			} else {
+				name += " value"
+			}
+		}
+
+		line := fmt.Sprintf("  %-24s %s", name, f.usage)
+		if f.env != "" {
+			line += " (env: " + f.env + ")"
+		}
+
+		if f.def != "" {
+			line += fmt.Sprintf(" (default %q)", f.def)
+		}
+
+		fmt.Fprintln(w, line)
+	}
+}
@@ -0,0 +1,162 @@
+package uboot
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBootRun(t *testing.T) {
+	type globalFlags struct {
+		Config string `flag:"config" usage:"config file" env:"UBOOT_TEST_CONFIG" default:"app.toml"`
+		Debug  bool   `flag:"debug" usage:"debug mode"`
+	}
+
+	type migrateFlags struct {
+		Steps   int           `flag:"steps" usage:"migrate steps" default:"1"`
+		Timeout time.Duration `flag:"timeout" usage:"migrate timeout" env:"UBOOT_TEST_TIMEOUT"`
+	}
+
+	newBoot := func() (*Boot, *globalFlags, *migrateFlags, func() []string) {
+		g, m := &globalFlags{}, &migrateFlags{}
+		ran, mu := []string{}, &sync.Mutex{}
+		record := func(c *Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, c.Name())
+			return nil
+		}
+
+		b := NewBoot().Signals().SetOutput(&bytes.Buffer{}).Flags(g).
+			Command("serve", "start http server").
+			Command("migrate up", "apply migrations", m).
+			Register(
+				Uint("config", UintFront, record),
+				Uint("http", UintNormal, record).Commands("serve"),
+				Uint("migrate", UintNormal, record).DependsOn("config").Commands("migrate up"),
+			)
+
+		return b, g, m, func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			sort.Strings(ran)
+			return ran
+		}
+	}
+
+	t.Run("Command", func(t *testing.T) {
+		t.Setenv("UBOOT_TEST_TIMEOUT", "5s")
+
+		b, g, m, ran := newBoot()
+		if e := b.Run([]string{"app", "migrate", "up", "-steps", "3", "-debug", "extra"}); e != nil {
+			t.Fatal(e)
+		}
+
+		if s := strings.Join(ran(), ","); s != "config,migrate" {
+			t.Fatalf("ran: %s", s)
+		}
+
+		if g.Config != "app.toml" || !g.Debug || m.Steps != 3 || m.Timeout != 5*time.Second {
+			t.Fatalf("flags: %+v %+v", g, m)
+		}
+
+		if b.CommandName() != "migrate up" || strings.Join(b.Args(), ",") != "extra" {
+			t.Fatalf("command: %s %v", b.CommandName(), b.Args())
+		}
+	})
+
+	t.Run("Help", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		b, _, _, ran := newBoot()
+		b.SetOutput(out)
+
+		if e := b.Run([]string{"app", "migrate", "up", "--help"}); !errors.Is(e, ErrHelp) {
+			t.Fatalf("expected help, got %v", e)
+		}
+
+		for _, s := range []string{"Usage: app migrate up", "-steps int", `(default "1")`, "(env: UBOOT_TEST_CONFIG)"} {
+			if !strings.Contains(out.String(), s) {
+				t.Fatalf("help missing %q:\n%s", s, out)
+			}
+		}
+
+		if len(ran()) > 0 {
+			t.Fatal("units ran on help")
+		}
+	})
+
+	t.Run("SameName", func(t *testing.T) {
+		ran := []string{}
+		b := NewBoot().Signals().SetOutput(&bytes.Buffer{}).AllowNameRepeat().
+			Command("serve", "start http server").
+			Command("migrate", "apply migrations").
+			Register(
+				Uint("store", UintFront, func(c *Context) error { ran = append(ran, "serve store"); return nil }).Commands("serve"),
+				Uint("store", UintFront, func(c *Context) error { ran = append(ran, "migrate store"); return nil }).Commands("migrate"),
+				Uint("migrate", UintNormal, func(c *Context) error { ran = append(ran, "migrate"); return nil }).
+					DependsOn("store").Commands("migrate"),
+			)
+
+		// 过滤掉的同名模块不能删除选中模块的 require
+		if e := b.Run([]string{"app", "migrate"}); e != nil {
+			t.Fatal(e)
+		}
+
+		if s := strings.Join(ran, ","); s != "migrate store,migrate" {
+			t.Fatalf("ran: %s", s)
+		}
+	})
+
+	t.Run("Depends", func(t *testing.T) {
+		ran := []string{}
+		b := NewBoot().Signals().SetOutput(&bytes.Buffer{}).
+			Command("serve", "start http server").
+			Command("migrate", "apply migrations").
+			Register(
+				Uint("db", UintFront, func(c *Context) error { ran = append(ran, "db"); return nil }).Commands("serve"),
+				Uint("http", UintNormal, func(c *Context) error { ran = append(ran, "http"); return nil }).Commands("serve"),
+				Uint("migrate", UintNormal, func(c *Context) error { ran = append(ran, "migrate"); return nil }).
+					DependsOn("db").Commands("migrate"),
+			)
+
+		// 选中模块依赖的其它子命令模块需要保留
+		if e := b.Run([]string{"app", "migrate"}); e != nil {
+			t.Fatal(e)
+		}
+
+		if s := strings.Join(ran, ","); s != "db,migrate" {
+			t.Fatalf("ran: %s", s)
+		}
+	})
+
+	t.Run("NoCommand", func(t *testing.T) {
+		g, ran := &globalFlags{}, false
+		b := NewBoot().Signals().SetOutput(&bytes.Buffer{}).Flags(g).
+			Register(Uint("http", UintNormal, func(c *Context) error { ran = true; return nil }))
+
+		// 没有注册子命令时直接启动
+		if e := b.Run([]string{"app", "-debug"}); e != nil {
+			t.Fatal(e)
+		}
+
+		if !ran || !g.Debug || b.CommandName() != "" {
+			t.Fatalf("ran: %v flags: %+v command: %q", ran, g, b.CommandName())
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		b, _, _, _ := newBoot()
+		if e := b.Run([]string{"app", "worker"}); e == nil || !strings.Contains(e.Error(), "unknown command") {
+			t.Fatalf("expected unknown command, got %v", e)
+		}
+
+		b, _, _, _ = newBoot()
+		if e := b.Run([]string{"app"}); !errors.Is(e, ErrCommandRequired) {
+			t.Fatalf("expected command required, got %v", e)
+		}
+	})
+}
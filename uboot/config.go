@@ -0,0 +1,238 @@
+package uboot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"uw/pkg/cast"
+	"uw/ulog"
+	"uw/utoml"
+)
+
+var (
+	DefaultConfigKey   = NewKey("uboot.config")              // 默认配置存储键
+	DefaultConfigEvent = NewEventKey("uboot.config.changed") // 默认配置变更事件
+)
+
+type ConfigOptions struct {
+	Name     string        // 模块名称, 默认 config
+	Key      Key           // 配置存储键, 默认 DefaultConfigKey
+	Event    EventKey      // 配置变更事件, 默认 DefaultConfigEvent
+	Watch    time.Duration // 轮询文件修改时间的间隔, 为 0 时不监听
+	NoStrict bool          // 允许配置文件中出现结构体未定义的字段
+}
+
+// 配置模块
+// @description 使用 utoml 严格模式解码配置文件, 然后使用 env:"NAME" 标签的环境变量覆盖,
+// 检查 required:"true" 标签的字段不为零值, 最后以 *T 类型存入 uboot 存储.
+// 开启监听后配置文件变化时会重新加载并通过 Boot 的事件总线发布 *T 类型的变更事件
+// @param path 配置文件路径
+// @param opts 配置选项
+// @return 预启动模块
+func ConfigUint[T any](path string, opts ConfigOptions) *UintAgent {
+	if opts.Name == "" {
+		opts.Name = "config"
+	}
+
+	if opts.Key == (Key{}) {
+		opts.Key = DefaultConfigKey
+	}
+
+	if opts.Event == (EventKey{}) {
+		opts.Event = DefaultConfigEvent
+	}
+
+	key := TypedKey[*T]{Key: opts.Key}
+
+	return Uint(opts.Name, UintFront, func(c *Context) error {
+		v, modTime, e := LoadConfig[T](path, !opts.NoStrict)
+		if e != nil {
+			return e
+		}
+
+		key.Set(v)
+		c.Printf("config loaded: %s", path)
+
+		if opts.Watch > 0 {
+			go watchConfig(c, path, opts, key, modTime)
+		}
+
+		return nil
+	})
+}
+
+// 加载配置文件
+// @param path 配置文件路径
+// @param strict 是否禁止未定义的字段
+// @return 配置, 文件修改时间
+func LoadConfig[T any](path string, strict bool) (*T, time.Time, error) {
+	fi, e := os.Stat(path)
+	if e != nil {
+		return nil, time.Time{}, e
+	}
+
+	b, e := os.ReadFile(path)
+	if e != nil {
+		return nil, time.Time{}, e
+	}
+
+	v := new(T)
+
+	d := utoml.NewDecoder(bytes.NewReader(b))
+	if strict {
+		d.DisallowUnknownFields()
+	}
+
+	if e := d.Decode(v); e != nil {
+		var se *utoml.StrictMissingError
+		if errors.As(e, &se) {
+			return nil, time.Time{}, fmt.Errorf("decode config %s: %s", path, se.String())
+		}
+
+		return nil, time.Time{}, fmt.Errorf("decode config %s: %w", path, e)
+	}
+
+	if e := applyEnv(reflect.ValueOf(v).Elem(), ""); e != nil {
+		return nil, time.Time{}, e
+	}
+
+	if e := checkRequired(reflect.ValueOf(v).Elem(), ""); e != nil {
+		return nil, time.Time{}, e
+	}
+
+	return v, fi.ModTime(), nil
+}
+
+func watchConfig[T any](c *Context, path string, opts ConfigOptions, key TypedKey[*T], modTime time.Time) {
+	for {
+		t := c.b.clock.NewTimer(opts.Watch)
+		select {
+		case <-c.b.ctx.Done():
+			t.Stop()
+			return
+		case <-t.C():
+		}
+
+		fi, e := os.Stat(path)
+		if e != nil || fi.ModTime().Equal(modTime) {
+			continue
+		}
+
+		v, mt, e := LoadConfig[T](path, !opts.NoStrict)
+		if e != nil {
+			c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "config reload error: %s"), e)
+			modTime = fi.ModTime()
+			continue
+		}
+
+		modTime = mt
+		key.Set(v)
+		c.Printf("config reloaded: %s", path)
+
+		if e := c.Events().Publish(context.Background(), opts.Event, v); e != nil && !errors.Is(e, ErrEventNotFound) {
+			c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "config changed event error: %s"), e)
+		}
+	}
+}
+
+func fieldPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "." + name
+}
+
+// 使用 env 标签的环境变量覆盖字段值
+func applyEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		if name := f.Tag.Get("env"); name != "" {
+			if s, ok := os.LookupEnv(name); ok {
+				if e := setFieldString(fv, s); e != nil {
+					return fmt.Errorf("config env %s => %s: %w", name, fieldPath(prefix, f.Name), e)
+				}
+			}
+
+			continue
+		}
+
+		if fv.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Time{}) {
+			if e := applyEnv(fv, fieldPath(prefix, f.Name)); e != nil {
+				return e
+			}
+		}
+	}
+
+	return nil
+}
+
+// 检查 required 标签的字段不为零值
+func checkRequired(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		if f.Tag.Get("required") == "true" && fv.IsZero() {
+			return fmt.Errorf("config field required: %s", fieldPath(prefix, f.Name))
+		}
+
+		if fv.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Time{}) {
+			if e := checkRequired(fv, fieldPath(prefix, f.Name)); e != nil {
+				return e
+			}
+		}
+	}
+
+	return nil
+}
+
+// 将字符串转换为字段类型并设置
+func setFieldString(v reflect.Value, s string) (e error) {
+	var val any
+
+	switch v.Interface().(type) {
+	case time.Duration:
+		val, e = cast.ToDurationE(s)
+	case time.Time:
+		val, e = cast.ToTimeE(s)
+	case []string:
+		val = strings.Split(s, ",")
+	default:
+		switch v.Kind() {
+		case reflect.String:
+			val = s
+		case reflect.Bool:
+			val, e = cast.ToBoolE(s)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			val, e = cast.ToInt64E(s)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			val, e = cast.ToUint64E(s)
+		case reflect.Float32, reflect.Float64:
+			val, e = cast.ToFloat64E(s)
+		default:
+			return fmt.Errorf("unsupported type: %s", v.Type())
+		}
+	}
+
+	if e != nil {
+		return e
+	}
+
+	v.Set(reflect.ValueOf(val).Convert(v.Type()))
+	return nil
+}
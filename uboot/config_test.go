@@ -0,0 +1,85 @@
+package uboot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type configTest struct {
+	Name    string        `toml:"name" required:"true"`
+	Port    int           `toml:"port" env:"UBOOT_TEST_PORT"`
+	Timeout time.Duration `toml:"timeout" env:"UBOOT_TEST_TIMEOUT"`
+	DB      struct {
+		Hosts []string `toml:"hosts" env:"UBOOT_TEST_HOSTS"`
+	} `toml:"db"`
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("name = \"app\"\nport = 80\n[db]\nhosts = [\"a\"]\n"), 0o644)
+
+	t.Setenv("UBOOT_TEST_PORT", "8080")
+	t.Setenv("UBOOT_TEST_TIMEOUT", "3s")
+	t.Setenv("UBOOT_TEST_HOSTS", "b,c")
+
+	v, _, e := LoadConfig[configTest](path, true)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if v.Name != "app" || v.Port != 8080 || v.Timeout != 3*time.Second || strings.Join(v.DB.Hosts, ",") != "b,c" {
+		t.Fatalf("config: %+v", v)
+	}
+
+	os.WriteFile(path, []byte("port = 80\n"), 0o644)
+	if _, _, e := LoadConfig[configTest](path, true); e == nil || !strings.Contains(e.Error(), "Name") {
+		t.Fatalf("expected required error, got %v", e)
+	}
+
+	os.WriteFile(path, []byte("name = \"app\"\nunknown = 1\n"), 0o644)
+	if _, _, e := LoadConfig[configTest](path, true); e == nil {
+		t.Fatal("expected strict error")
+	}
+}
+
+func TestConfigUintWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("name = \"v1\"\n"), 0o644)
+
+	key := NewKey("test.config")
+	Remove(key)
+	defer Remove(key)
+	b := NewBoot().Signals().Register(
+		ConfigUint[configTest](path, ConfigOptions{Key: key, Watch: 10 * time.Millisecond}),
+	)
+
+	changed := make(chan string, 1)
+	SubscribeOn(b.Events(), DefaultConfigEvent, func(ctx context.Context, c *configTest) error {
+		changed <- c.Name
+		return nil
+	})
+
+	go b.Start()
+	defer b.Shutdown(context.Background())
+
+	if v, e := LoadWait[*configTest](context.Background(), key); e != nil || v.Name != "v1" {
+		t.Fatalf("load: %v %v", v, e)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	os.WriteFile(path, []byte("name = \"v2\"\n"), 0o644)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+
+	select {
+	case name := <-changed:
+		if name != "v2" || Get[*configTest](key).Name != "v2" {
+			t.Fatalf("reloaded: %s", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("config not reloaded")
+	}
+}
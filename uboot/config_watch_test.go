@@ -0,0 +1,62 @@
+package uboot_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uw/uboot"
+	"uw/uboot/uboottest"
+)
+
+type watchConfig struct {
+	Name string `toml:"name"`
+}
+
+func TestConfigWatchClock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("name = \"v1\"\n"), 0o644)
+
+	key := uboot.NewKey("test.config.clock")
+	defer uboot.Remove(key)
+
+	h := uboottest.New()
+	h.Boot.Register(uboot.ConfigUint[watchConfig](path, uboot.ConfigOptions{Key: key, Watch: time.Minute}))
+
+	changed := make(chan string, 1)
+	uboot.SubscribeOn(h.Boot.Events(), uboot.DefaultConfigEvent, func(ctx context.Context, c *watchConfig) error {
+		changed <- c.Name
+		return nil
+	})
+
+	done := h.StartAsync()
+	defer func() {
+		h.Shutdown()
+		<-done
+	}()
+
+	if v, e := uboot.LoadWait[*watchConfig](context.Background(), key); e != nil || v.Name != "v1" {
+		t.Fatalf("load: %v %v", v, e)
+	}
+
+	if !h.Clock.WaitTimers(1, 2*time.Second) {
+		t.Fatal("watcher not waiting")
+	}
+
+	os.WriteFile(path, []byte("name = \"v2\"\n"), 0o644)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+
+	// 重新加载由假时钟驱动
+	h.Clock.Advance(time.Minute)
+
+	select {
+	case name := <-changed:
+		if name != "v2" {
+			t.Fatalf("reloaded: %s", name)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("config not reloaded")
+	}
+}
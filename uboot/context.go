@@ -3,16 +3,21 @@ package uboot
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	"uw/ulog"
 )
 
 type Context struct {
 	b            *Boot
 	u            *UintAgent
-	printf       Printf
 	ctx          context.Context
 	cancel       context.CancelFunc
-	timeoutTimer *time.Timer
+	timeoutTimer Timer
+	timedOut     atomic.Bool
+	timing       *uintTiming // 第一次运行的耗时记录
 }
 
 func (c *Context) Name() string {
@@ -27,29 +32,72 @@ func (c *Context) Timeout() time.Duration {
 	return c.u.timeout
 }
 
+// 是否因为超时被取消
+func (c *Context) TimedOut() bool {
+	return c.timedOut.Load()
+}
+
+// 通过上下文中的日志输出, 日志带有模块名称字段 uint
+// 通过 SetPrintf 设置了打印函数且没有通过 SetLogger 设置日志时, 使用打印函数输出并带有模块前缀
 func (c *Context) Printf(format string, args ...interface{}) {
-	c.printf(format, args...)
+	if c.b.customPrintf && c.b.logger == nil {
+		prefix := ulog.ANSI.Bold + "[" + strings.ToUpper(UintTypeString(c.u.utype)) + ":" + c.u.name + "]" + ulog.ANSI.Reset + " "
+		c.b.printf(prefix + ulog.ANSI.Grey + fmt.Sprintf(format, args...) + ulog.ANSI.Reset)
+		return
+	}
+
+	c.Logger().Log(ulog.LevelPrintf, 2, format, args...)
 }
 
+// 模块的上下文, 携带带有 uint 字段的日志, 参考 ulog.FromContext
 func (c *Context) Context() context.Context {
 	return c.ctx
 }
 
+// 上下文中的日志, 每一条日志都带有模块名称字段 uint
+func (c *Context) Logger() *ulog.Logger {
+	return ulog.FromContext(c.ctx)
+}
+
+// 模块所属的 Boot
+func (c *Context) Boot() *Boot {
+	return c.b
+}
+
+// 选中的子命令, 未使用 Boot.Run 时为空
+func (c *Context) Command() string {
+	return c.b.commandName
+}
+
+func (c *Context) Events() *EventBus {
+	return c.b.Events()
+}
+
 func (c *Context) Cancel() {
 	c.cancel()
 }
 
+// 等待模块完成, 模块失败时返回它的错误
 func (c *Context) Require(ctx context.Context, name string) error {
-	c.printf("require: %s", name)
+	c.Printf("require: %s", name)
 
 	if cwc := c.b.require.Get(name); cwc != nil {
 		c.cancelTimeout()
 		defer c.timeout()
 
+		start := c.b.now()
+		defer func() {
+			c.timing.require(name, start, c.b.now())
+		}()
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-cwc.Done():
+			if e := cwc.Failed(); e != nil {
+				return fmt.Errorf("require %s failed: %w", name, e)
+			}
+
 			return nil
 		}
 	}
@@ -57,6 +105,37 @@ func (c *Context) Require(ctx context.Context, name string) error {
 	return fmt.Errorf("require %s not found", name)
 }
 
+func (c *Context) waitDepends() error {
+	if len(c.u.depends) < 1 {
+		return nil
+	}
+
+	c.Printf("waiting for depends: %s", strings.Join(c.u.depends, ", "))
+
+	for _, name := range c.u.depends {
+		cwc := c.b.require.Get(name)
+		if cwc == nil {
+			return fmt.Errorf("depend %s not found", name)
+		}
+
+		start := c.b.now()
+
+		select {
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		case <-cwc.Done():
+		}
+
+		c.timing.require(name, start, c.b.now())
+
+		if e := cwc.Failed(); e != nil {
+			return fmt.Errorf("depend %s failed: %w", name, e)
+		}
+	}
+
+	return nil
+}
+
 func (c *Context) timeout() {
 	if c.u.timeout > 0 {
 		if c.timeoutTimer != nil {
@@ -64,15 +143,15 @@ func (c *Context) timeout() {
 			return
 		}
 
-		c.timeoutTimer = time.AfterFunc(c.u.timeout, func() {
-			c.Cancel()
+		c.timeoutTimer = c.b.clock.AfterFunc(c.u.timeout, func() {
+			c.timedOut.Store(true)
+			c.Printf("uint start timeout")
 
 			if !c.u.recover {
-				c.Printf("uint start timeout")
-				panic(fmt.Sprintf("[%s] uint start timeout", c.u.name))
+				c.b.fail(fmt.Errorf("[%s] %w", c.u.name, ErrUintTimeout))
 			}
 
-			c.Printf("uint start timeout")
+			c.Cancel()
 		})
 	}
 }
@@ -0,0 +1,83 @@
+package uboot_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"uw/uboot"
+	"uw/uboot/uboottest"
+	"uw/ulog"
+)
+
+func TestContextLogger(t *testing.T) {
+	lines := []string{}
+	df := ulog.NewDefaultFormat(func(s string) { lines = append(lines, s) })
+	df.SetLevel(ulog.DefaultLevelNoColor)
+
+	h := uboottest.New()
+	h.Boot.SetLogger(ulog.NewLogger(df))
+	h.Register(uboot.Uint("db", uboot.UintFront, func(c *uboot.Context) error {
+		ulog.FromContext(c.Context()).With("table", "users").Info("migrated")
+		return nil
+	}))
+
+	if e := h.Start(); e != nil {
+		t.Fatal(e)
+	}
+	h.Shutdown()
+
+	// Context.Printf 同样通过上下文中的日志输出
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], "uint starting uint=db\r\n") ||
+		!strings.HasSuffix(lines[1], "migrated uint=db table=users\r\n") {
+		t.Fatalf("lines: %q", lines)
+	}
+}
+
+func TestContextPrintf(t *testing.T) {
+	lines := []string{}
+	b := uboot.NewBoot().Signals().SetPrintf(func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	})
+
+	b.Register(uboot.Uint("db", uboot.UintFront, func(c *uboot.Context) error {
+		c.Printf("migrated %d", 3)
+		return nil
+	}))
+
+	if e := b.StartE(); e != nil {
+		t.Fatal(e)
+	}
+
+	// 没有设置 SetLogger 时, 模块输出使用 SetPrintf 设置的打印函数
+	found := false
+	for _, l := range lines {
+		found = found || strings.Contains(l, "[FRONT:db]") && strings.Contains(l, "migrated 3")
+	}
+
+	if !found {
+		t.Fatalf("lines: %q", lines)
+	}
+}
+
+func TestUintPanicStack(t *testing.T) {
+	lines := []string{}
+	df := ulog.NewDefaultFormat(func(s string) { lines = append(lines, s) })
+	df.SetLevel(ulog.LevelError)
+
+	h := uboottest.New()
+	h.Boot.SetLogger(ulog.NewLogger(df))
+	h.Register(uboot.Uint("db", uboot.UintFront, func(c *uboot.Context) error {
+		var m map[string]int
+		m["users"]++
+		return nil
+	}).Recover())
+
+	h.Start()
+	h.Shutdown()
+
+	if len(lines) != 1 || !strings.Contains(lines[0], "panic: assignment to entry in nil map uint=db") ||
+		!strings.Contains(lines[0], "Error Stack:\r\n1.uw/uboot_test.TestUintPanicStack.func") {
+		t.Fatalf("lines: %q", lines)
+	}
+}
@@ -0,0 +1,481 @@
+package uboot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"uw/ulog"
+)
+
+type CronOverlap uint8
+
+const (
+	CronSkip       CronOverlap = iota // 上一次运行未完成时跳过本次 (默认)
+	CronQueue                         // 上一次运行完成后依次补上
+	CronConcurrent                    // 并发运行
+)
+
+type CronMissed uint8
+
+const (
+	CronMissedRunOnce CronMissed = iota // 错过的多次运行只补一次 (默认)
+	CronMissedSkip                      // 跳过错过的运行
+	CronMissedRunAll                    // 补上每一次错过的运行
+)
+
+const cronMissedMax = 1024 // 最多补上的运行次数
+
+type CronOptions struct {
+	Location *time.Location // 时区, 默认 time.Local
+	Overlap  CronOverlap    // 运行重叠时的处理策略
+	Missed   CronMissed     // 错过运行 (例如长时间 GC 暂停或者系统休眠) 时的处理策略
+}
+
+// 定时计划
+type CronSchedule interface {
+	Next(t time.Time) time.Time // 返回 t 之后的下一次运行时间, 没有时返回零值
+}
+
+// 固定间隔计划
+type everySchedule struct {
+	every time.Duration
+}
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.every)
+}
+
+// cron 表达式计划, 每个字段是一个位图
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	location                              *time.Location
+}
+
+type cronBounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	cronSeconds = cronBounds{0, 59, nil}
+	cronMinutes = cronBounds{0, 59, nil}
+	cronHours   = cronBounds{0, 23, nil}
+	cronDom     = cronBounds{1, 31, nil}
+	cronMonths  = cronBounds{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronBounds{0, 6, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 0 1 1 *",
+		"@annually": "0 0 0 1 1 *",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * * 0",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
+	}
+)
+
+const cronStar = 1 << 63 // 字段为 * 的标记, 用于日期和星期的匹配规则
+
+// 解析定时表达式
+// @description 支持 5 个字段 (分 时 日 月 周), 6 个字段 (秒 分 时 日 月 周),
+// @every <时长> 以及 @daily/@hourly 等描述符
+// @param spec 表达式
+// @param location 时区, 为 nil 时使用 time.Local
+// @return 定时计划
+func ParseCron(spec string, location *time.Location) (CronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if location == nil {
+		location = time.Local
+	}
+
+	if strings.HasPrefix(spec, "@every ") {
+		d, e := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if e != nil {
+			return nil, fmt.Errorf("cron %q: %w", spec, e)
+		}
+
+		if d < time.Second {
+			return nil, fmt.Errorf("cron %q: interval must be at least 1s", spec)
+		}
+
+		return everySchedule{every: d}, nil
+	}
+
+	if v, ok := cronDescriptors[spec]; ok {
+		spec = v
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron %q: expected 5 or 6 fields, got %d", spec, len(fields))
+	}
+
+	s, e := &cronSchedule{location: location}, error(nil)
+	for i, f := range []struct {
+		field  *uint64
+		bounds cronBounds
+	}{
+		{&s.second, cronSeconds},
+		{&s.minute, cronMinutes},
+		{&s.hour, cronHours},
+		{&s.dom, cronDom},
+		{&s.month, cronMonths},
+		{&s.dow, cronDow},
+	} {
+		if *f.field, e = parseCronField(fields[i], f.bounds); e != nil {
+			return nil, fmt.Errorf("cron %q: %w", spec, e)
+		}
+	}
+
+	return s, nil
+}
+
+func parseCronField(field string, b cronBounds) (uint64, error) {
+	bitsSet := uint64(0)
+
+	for _, expr := range strings.Split(field, ",") {
+		rangeExpr, step := expr, uint(1)
+		if i := strings.IndexByte(expr, '/'); i >= 0 {
+			n, e := strconv.ParseUint(expr[i+1:], 10, 8)
+			if e != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step: %s", expr)
+			}
+
+			rangeExpr, step = expr[:i], uint(n)
+		}
+
+		start, end, star := b.min, b.max, false
+		switch {
+		case rangeExpr == "*" || rangeExpr == "?":
+			star = step == 1
+		case strings.Contains(rangeExpr, "-"):
+			parts := strings.SplitN(rangeExpr, "-", 2)
+			var e error
+			if start, e = parseCronValue(parts[0], b); e != nil {
+				return 0, e
+			}
+			if end, e = parseCronValue(parts[1], b); e != nil {
+				return 0, e
+			}
+		default:
+			var e error
+			if start, e = parseCronValue(rangeExpr, b); e != nil {
+				return 0, e
+			}
+
+			if step == 1 {
+				end = start
+			}
+		}
+
+		if start > end {
+			return 0, fmt.Errorf("invalid range: %s", expr)
+		}
+
+		for i := start; i <= end; i += step {
+			bitsSet |= 1 << i
+		}
+
+		if star {
+			bitsSet |= cronStar
+		}
+	}
+
+	return bitsSet, nil
+}
+
+func parseCronValue(s string, b cronBounds) (uint, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	n, e := strconv.ParseUint(s, 10, 8)
+	if e != nil {
+		return 0, fmt.Errorf("invalid value: %s", s)
+	}
+
+	// 星期允许使用 7 表示周日
+	if b.names != nil && b.max == 6 && n == 7 {
+		n = 0
+	}
+
+	if uint(n) < b.min || uint(n) > b.max {
+		return 0, fmt.Errorf("value out of range [%d, %d]: %s", b.min, b.max, s)
+	}
+
+	return uint(n), nil
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	// 日期和星期都有限制时满足其一即可
+	if s.dom&cronStar != 0 || s.dow&cronStar != 0 {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	origin := t.Location()
+	t = t.In(s.location).Add(time.Second - time.Duration(t.Nanosecond()))
+	t = t.Truncate(time.Second)
+
+	yearLimit := t.Year() + 5
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for s.month&(1<<uint(t.Month())) == 0 {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !s.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		t = t.Truncate(time.Minute).Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	for s.second&(1<<uint(t.Second())) == 0 {
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+
+	return t.In(origin)
+}
+
+// 设置定时模块的计划
+// @description 依赖该模块的其它模块在第一次运行完成 (成功或失败) 后即被释放, 不会等待之后的运行
+// @param spec 定时表达式, 参考 ParseCron
+// @param opts 定时选项
+func (u *UintAgent) Schedule(spec string, opts ...CronOptions) *UintAgent {
+	o := CronOptions{}
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	u.cron = &cronState{opts: o}
+	u.cron.schedule, u.cron.err = ParseCron(spec, o.Location)
+	return u
+}
+
+// 定时模块运行记录
+type CronRun struct {
+	Runs     int           `json:"runs"`               // 运行次数
+	Skipped  int           `json:"skipped"`            // 跳过次数 (重叠或者错过)
+	LastRun  time.Time     `json:"last_run"`           // 上次运行开始时间
+	Duration time.Duration `json:"duration"`           // 上次运行耗时
+	Error    string        `json:"error,omitempty"`    // 上次运行错误
+	NextRun  time.Time     `json:"next_run,omitempty"` // 下次运行时间
+}
+
+type cronState struct {
+	opts     CronOptions
+	schedule CronSchedule
+	err      error
+
+	lock    sync.Mutex
+	run     CronRun
+	running int // 正在运行的数量
+	queued  int // 等待运行的数量
+}
+
+func (cs *cronState) history() CronRun {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	return cs.run
+}
+
+// 错过的运行次数, 返回 next 到 now 之间 (不含 next) 的计划时间数量以及之后的下一次运行时间
+func (cs *cronState) missed(next, now time.Time) (int, time.Time) {
+	n := 0
+	for t := cs.schedule.Next(next); !t.IsZero() && !t.After(now); t = cs.schedule.Next(t) {
+		if n++; n >= cronMissedMax {
+			return n, cs.schedule.Next(now)
+		}
+	}
+
+	return n, cs.schedule.Next(now)
+}
+
+func (b *Boot) runCron(u *UintAgent) {
+	u.recover = true
+	cs, c := u.cron, b.newContext(u)
+
+	u.status.set(UintStarting, nil)
+	if e := c.waitDepends(); e != nil {
+		u.status.set(UintFailed, e)
+		return
+	}
+	u.status.set(UintRunning, nil)
+
+	wg, first := &sync.WaitGroup{}, &sync.Once{}
+	defer wg.Wait()
+
+	dispatch := func() {
+		cs.lock.Lock()
+		switch {
+		case cs.running < 1 || cs.opts.Overlap == CronConcurrent:
+		case cs.opts.Overlap == CronQueue:
+			cs.queued++
+			cs.lock.Unlock()
+			return
+		default:
+			cs.run.Skipped++
+			cs.lock.Unlock()
+			c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "cron uint still running, skip"))
+			return
+		}
+		cs.running++
+		cs.lock.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				b.runCronOnce(u)
+				first.Do(func() {
+					if cwc := b.require.Get(u.name); cwc != nil {
+						cwc.Cancel()
+					}
+				})
+
+				cs.lock.Lock()
+				if cs.queued < 1 || b.stopping() {
+					cs.running--
+					cs.lock.Unlock()
+					return
+				}
+				cs.queued--
+				cs.lock.Unlock()
+			}
+		}()
+	}
+
+	next := cs.schedule.Next(b.now())
+	for !next.IsZero() {
+		cs.lock.Lock()
+		cs.run.NextRun = next
+		cs.lock.Unlock()
+
+		t := b.clock.NewTimer(next.Sub(b.now()))
+		select {
+		case <-b.ctx.Done():
+			t.Stop()
+			u.status.set(UintStopped, nil)
+			return
+		case <-t.C():
+		}
+
+		n, after := cs.missed(next, b.now())
+		if n > 0 {
+			c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "cron uint missed %d runs"), n)
+		}
+
+		runs := 1
+		switch cs.opts.Missed {
+		case CronMissedSkip:
+			if n > 0 {
+				runs = 0
+			}
+		case CronMissedRunAll:
+			runs += n
+		}
+
+		cs.lock.Lock()
+		cs.run.Skipped += n + 1 - runs
+		cs.lock.Unlock()
+
+		for i := 0; i < runs; i++ {
+			dispatch()
+		}
+
+		next = after
+	}
+
+	u.status.set(UintStopped, nil)
+}
+
+func (b *Boot) runCronOnce(u *UintAgent) {
+	cs, c := u.cron, b.newContext(u)
+	start := b.now()
+
+	e := func() (e error) {
+		defer func() {
+			if r := recover(); r != nil {
+				e = &PanicError{Value: r}
+				c.Logger().LogPanic(e)
+			}
+		}()
+
+		c.timeout()
+		defer c.cancelTimeout()
+		defer c.Cancel()
+
+		return u.handler(c)
+	}()
+
+	cs.lock.Lock()
+	cs.run.Runs++
+	cs.run.LastRun, cs.run.Duration, cs.run.Error = start, b.now().Sub(start), ""
+	if e != nil {
+		cs.run.Error = e.Error()
+	}
+	cs.lock.Unlock()
+
+	if e != nil {
+		c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "cron uint run error: %s"), e)
+		u.status.set(UintRunning, e)
+		return
+	}
+
+	c.Printf("cron uint run success (%s)", b.now().Sub(start))
+}
+
+func (u *UintAgent) checkSchedule() error {
+	if u.cron == nil {
+		return errors.New("cron uint without schedule")
+	}
+
+	return u.cron.err
+}
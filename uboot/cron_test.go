@@ -0,0 +1,52 @@
+package uboot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	base := time.Date(2024, 1, 31, 10, 15, 30, 0, time.UTC)
+
+	for _, c := range []struct {
+		spec string
+		loc  *time.Location
+		want time.Time
+	}{
+		{"*/5 * * * *", time.UTC, time.Date(2024, 1, 31, 10, 20, 0, 0, time.UTC)},
+		{"*/10 * * * * *", time.UTC, time.Date(2024, 1, 31, 10, 15, 40, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.UTC, time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.UTC, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * sun", time.UTC, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.UTC, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@daily", shanghai, time.Date(2024, 1, 31, 16, 0, 0, 0, time.UTC)},
+		{"@every 5m", time.UTC, base.Add(5 * time.Minute)},
+	} {
+		s, e := ParseCron(c.spec, c.loc)
+		if e != nil {
+			t.Fatalf("%s: %s", c.spec, e)
+		}
+
+		if next := s.Next(base); !next.Equal(c.want) {
+			t.Fatalf("%s: next %s, want %s", c.spec, next, c.want)
+		}
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "@every 1ms", "*/0 * * * *"} {
+		if _, e := ParseCron(spec, nil); e == nil {
+			t.Fatalf("%s: expected error", spec)
+		}
+	}
+}
+
+func TestCronMissed(t *testing.T) {
+	s, _ := ParseCron("@every 1m", nil)
+	cs := &cronState{schedule: s}
+
+	next := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	n, after := cs.missed(next, next.Add(10*time.Minute+30*time.Second))
+	if n != 10 || !after.Equal(next.Add(11*time.Minute+30*time.Second)) {
+		t.Fatalf("missed %d, next %s", n, after)
+	}
+}
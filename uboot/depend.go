@@ -0,0 +1,266 @@
+package uboot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 依赖图, 在 Start 前解析, 用于检查未知依赖/循环依赖并确定启动顺序
+type dependGraph struct {
+	units map[string][]*UintAgent // 名称 => 模块 (允许重名时可能有多个)
+	edges map[string][]string     // 名称 => 依赖名称
+	order []string                // 名称注册顺序
+}
+
+func (b *Boot) phases() [][]*UintAgent {
+	b.planLock.RLock()
+	defer b.planLock.RUnlock()
+
+	return [][]*UintAgent{b.frontUint, b.backgroundUint, b.normalUint, b.daemonUint, b.afterUint, b.cronUint}
+}
+
+// 阻塞型运行时机 (等待完成后才进入下一个运行时机), 不能依赖更晚运行时机的模块
+func blockingUintType(utype UintType) bool {
+	return utype == UintFront || utype == UintNormal || utype == UintAfter
+}
+
+func (b *Boot) dependGraph() *dependGraph {
+	g := &dependGraph{
+		units: map[string][]*UintAgent{},
+		edges: map[string][]string{},
+	}
+
+	for _, phase := range b.phases() {
+		for _, u := range phase {
+			if _, ok := g.units[u.name]; !ok {
+				g.order = append(g.order, u.name)
+			}
+
+			g.units[u.name] = append(g.units[u.name], u)
+			g.edges[u.name] = append(g.edges[u.name], u.depends...)
+		}
+	}
+
+	return g
+}
+
+func (g *dependGraph) check() error {
+	for _, name := range g.order {
+		for _, u := range g.units[name] {
+			for _, dep := range u.depends {
+				if _, ok := g.units[dep]; !ok {
+					return fmt.Errorf("uint %s depends on unknown uint: %s", u.name, dep)
+				}
+			}
+		}
+	}
+
+	const (
+		white = iota // 未访问
+		grey         // 访问中
+		black        // 已完成
+	)
+
+	color, stack := map[string]int{}, []string{}
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		color[name] = grey
+		stack = append(stack, name)
+
+		for _, dep := range g.edges[name] {
+			switch color[dep] {
+			case grey:
+				i := len(stack) - 1
+				for ; i > 0 && stack[i] != dep; i-- {
+				}
+
+				return fmt.Errorf("uint depends cycle: %s -> %s",
+					strings.Join(stack[i:], " -> "), dep)
+			case white:
+				if e := visit(dep); e != nil {
+					return e
+				}
+			}
+		}
+
+		color[name] = black
+		stack = stack[:len(stack)-1]
+		return nil
+	}
+
+	for _, name := range g.order {
+		if color[name] == white {
+			if e := visit(name); e != nil {
+				return e
+			}
+		}
+	}
+
+	return g.checkPhases()
+}
+
+// 阻塞型模块的所有间接依赖都不能在更晚的运行时机,
+// 例如 normal -> background -> after 中 after 要等 normal 完成后才运行, 启动会一直等待
+// @description 需要在循环检查之后调用
+func (g *dependGraph) checkPhases() error {
+	for _, name := range g.order {
+		for _, u := range g.units[name] {
+			if !blockingUintType(u.utype) {
+				continue
+			}
+
+			visited, path := map[string]bool{}, []string{u.name}
+
+			var walk func(name string) error
+			walk = func(name string) error {
+				for _, dep := range g.edges[name] {
+					if visited[dep] {
+						continue
+					}
+					visited[dep] = true
+					path = append(path, dep)
+
+					for _, d := range g.units[dep] {
+						if d.utype > u.utype {
+							return fmt.Errorf("%s uint %s can not depend on later %s uint %s: %s",
+								UintTypeString(u.utype), u.name, UintTypeString(d.utype), d.name,
+								strings.Join(path, " -> "))
+						}
+					}
+
+					if e := walk(dep); e != nil {
+						return e
+					}
+					path = path[:len(path)-1]
+				}
+
+				return nil
+			}
+
+			if e := walk(u.name); e != nil {
+				return e
+			}
+		}
+	}
+
+	return nil
+}
+
+// 同一运行时机内按依赖拓扑排序, 无依赖关系的模块保持注册顺序
+func sortUint(list []*UintAgent) []*UintAgent {
+	inPhase := map[string]bool{}
+	for _, u := range list {
+		inPhase[u.name] = true
+	}
+
+	sorted, done := make([]*UintAgent, 0, len(list)), map[*UintAgent]bool{}
+	started := map[string]int{} // 名称 => 已排序的模块数量
+	count := map[string]int{}
+	for _, u := range list {
+		count[u.name]++
+	}
+
+	for len(sorted) < len(list) {
+		progress := false
+
+		for _, u := range list {
+			if done[u] {
+				continue
+			}
+
+			ready := true
+			for _, dep := range u.depends {
+				if inPhase[dep] && started[dep] < count[dep] {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				sorted, done[u] = append(sorted, u), true
+				started[u.name]++
+				progress = true
+				break
+			}
+		}
+
+		// 已经过循环检查, 这里只是防御
+		if !progress {
+			for _, u := range list {
+				if !done[u] {
+					sorted = append(sorted, u)
+				}
+			}
+		}
+	}
+
+	return sorted
+}
+
+// 按依赖分层, 同一层的模块互不依赖, 可以同时启动
+// @param list 已经按依赖拓扑排序的同一运行时机的模块
+func layerUint(list []*UintAgent) [][]*UintAgent {
+	inPhase := map[string]bool{}
+	for _, u := range list {
+		inPhase[u.name] = true
+	}
+
+	layers, depth := [][]*UintAgent{}, map[string]int{}
+	for _, u := range list {
+		d := 0
+		for _, dep := range u.depends {
+			if inPhase[dep] {
+				d = max(d, depth[dep]+1)
+			}
+		}
+
+		// 允许重名时, 依赖该名称的模块在所有同名模块之后
+		depth[u.name] = max(depth[u.name], d)
+		for len(layers) <= d {
+			layers = append(layers, nil)
+		}
+		layers[d] = append(layers[d], u)
+	}
+
+	return layers
+}
+
+// 解析依赖图并重新排列各运行时机的模块顺序
+func (b *Boot) resolve() error {
+	if e := b.dependGraph().check(); e != nil {
+		return e
+	}
+
+	b.planLock.Lock()
+	defer b.planLock.Unlock()
+
+	b.frontUint = sortUint(b.frontUint)
+	b.backgroundUint = sortUint(b.backgroundUint)
+	b.normalUint = sortUint(b.normalUint)
+	b.daemonUint = sortUint(b.daemonUint)
+	b.afterUint = sortUint(b.afterUint)
+	b.cronUint = sortUint(b.cronUint)
+
+	return nil
+}
+
+func (b *Boot) printPlan() {
+	for _, phase := range b.phases() {
+		if len(phase) < 1 {
+			continue
+		}
+
+		items := make([]string, 0, len(phase))
+		for _, u := range phase {
+			if len(u.depends) > 0 {
+				items = append(items, u.name+"("+strings.Join(u.depends, ",")+")")
+				continue
+			}
+
+			items = append(items, u.name)
+		}
+
+		b.printf("plan %s uint: %s", UintTypeString(phase[0].utype), strings.Join(items, ", "))
+	}
+}
@@ -0,0 +1,141 @@
+package uboot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBootResolve(t *testing.T) {
+	noop := func(c *Context) error { return nil }
+
+	t.Run("Order", func(t *testing.T) {
+		b := NewBoot().Register(
+			Uint("c", UintFront, noop).DependsOn("b"),
+			Uint("b", UintFront, noop).DependsOn("a"),
+			Uint("a", UintFront, noop),
+			Uint("d", UintNormal, noop).DependsOn("c", "e"),
+			Uint("e", UintBackground, noop),
+		)
+
+		if e := b.resolve(); e != nil {
+			t.Fatal(e)
+		}
+
+		names := []string{}
+		for _, u := range b.frontUint {
+			names = append(names, u.name)
+		}
+
+		if s := strings.Join(names, ","); s != "a,b,c" {
+			t.Fatalf("front order: %s", s)
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		b := NewBoot().Register(Uint("a", UintNormal, noop).DependsOn("x"))
+		if e := b.resolve(); e == nil || !strings.Contains(e.Error(), "unknown") {
+			t.Fatalf("expected unknown error, got %v", e)
+		}
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		b := NewBoot().Register(
+			Uint("a", UintNormal, noop).DependsOn("b"),
+			Uint("b", UintNormal, noop).DependsOn("c"),
+			Uint("c", UintNormal, noop).DependsOn("a"),
+		)
+		if e := b.resolve(); e == nil || !strings.Contains(e.Error(), "a -> b -> c -> a") {
+			t.Fatalf("expected cycle error, got %v", e)
+		}
+	})
+
+	t.Run("LaterPhase", func(t *testing.T) {
+		b := NewBoot().Register(
+			Uint("a", UintFront, noop).DependsOn("b"),
+			Uint("b", UintNormal, noop),
+		)
+		if e := b.resolve(); e == nil {
+			t.Fatal("expected later phase error")
+		}
+	})
+	t.Run("TransitiveLaterPhase", func(t *testing.T) {
+		b := NewBoot().Register(
+			Uint("api", UintNormal, noop).DependsOn("cache"),
+			Uint("cache", UintBackground, noop).DependsOn("report"),
+			Uint("report", UintAfter, noop),
+		)
+		if e := b.resolve(); e == nil || !strings.Contains(e.Error(), "api -> cache -> report") {
+			t.Fatalf("expected transitive later phase error, got %v", e)
+		}
+	})
+}
+
+func TestDependFailed(t *testing.T) {
+	ran := false
+	b := NewBoot().Signals().Register(
+		Uint("db", UintFront, func(c *Context) error { return errors.New("connection refused") }).Recover(),
+		Uint("api", UintNormal, func(c *Context) error {
+			ran = true
+			return nil
+		}).DependsOn("db").Recover(),
+	)
+
+	b.Start()
+	b.Shutdown(context.Background())
+
+	var api *UintAgent
+	for _, u := range b.normalUint {
+		api = u
+	}
+
+	if st := api.Status(); ran || st.State != UintFailed ||
+		st.LastError != "depend db failed: connection refused" {
+		t.Fatalf("dependent: ran=%v %+v", ran, st)
+	}
+}
+
+func TestFrontConcurrent(t *testing.T) {
+	// 两个互不依赖的模块都启动后才能完成, 依次启动时会超时
+	started := &sync.WaitGroup{}
+	started.Add(2)
+	wait := func(c *Context) error {
+		started.Done()
+
+		done := make(chan struct{})
+		go func() {
+			started.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-time.After(2 * time.Second):
+			return errors.New("independent front uint not started concurrently")
+		}
+	}
+
+	ran := []string{}
+	b := NewBoot().Signals().Register(
+		Uint("a", UintFront, wait),
+		Uint("b", UintFront, wait),
+		Uint("c", UintFront, func(c *Context) error { ran = append(ran, "c"); return nil }).DependsOn("a", "b"),
+	)
+
+	if e := b.StartE(); e != nil {
+		t.Fatal(e)
+	}
+	b.Shutdown(context.Background())
+
+	if len(ran) != 1 {
+		t.Fatalf("dependent not started: %v", ran)
+	}
+
+	if layers := layerUint(b.frontUint); len(layers) != 2 || len(layers[0]) != 2 || layers[1][0].name != "c" {
+		t.Fatalf("layers: %v", layers)
+	}
+}
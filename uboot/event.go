@@ -7,7 +7,11 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"uw/ulog"
 	"uw/umap"
@@ -19,11 +23,11 @@ const (
 )
 
 var (
-	event                 = umap.NewHmap[string, *umap.Hmap[string, EventHandler[any]]]()
-	eventSubscribeRWMutex = &sync.RWMutex{}
-	eventQueue            = make([]*eventQueueData, 0, eventQueueSize)
-	eventQueueMutex       = &sync.Mutex{}
-	eventCond             = sync.NewCond(&sync.Mutex{})
+	ErrEventBusClosed   = errors.New("event bus closed")
+	ErrEventQueueFull   = errors.New("event queue full")
+	ErrEventNotFound    = errors.New("event not found")
+	defaultEventBus     *EventBus
+	defaultEventBusOnce = &sync.Once{}
 )
 
 type EventKey struct {
@@ -41,6 +45,7 @@ func NewEventKey(value string) EventKey {
 }
 
 type eventQueueData struct {
+	Ctx     context.Context
 	Key     EventKey
 	Data    any
 	OnError func(error)
@@ -48,52 +53,132 @@ type eventQueueData struct {
 
 type EventHandler[T any] func(ctx context.Context, data T) error
 
-func popEventQueue() *eventQueueData {
-	eventQueueMutex.Lock()
-	defer eventQueueMutex.Unlock()
+// 队列已满时的处理策略
+type QueueFullPolicy uint8
 
-	if len(eventQueue) < 1 {
-		return nil
-	}
+const (
+	QueueBlock QueueFullPolicy = iota // 阻塞直到队列有空位或者上下文取消 (默认)
+	QueueDrop                         // 丢弃事件
+	QueueError                        // 丢弃事件并返回 ErrEventQueueFull
+)
 
-	n := len(eventQueue)
-	x := eventQueue[0]
-	eventQueue = eventQueue[1:n]
+type EventBusOptions struct {
+	Capacity       int             // 队列容量, 默认 1024
+	Workers        int             // 队列处理协程数量, 默认 12
+	Policy         QueueFullPolicy // 队列已满时的处理策略
+	Ordered        bool            // 相同事件键的队列事件按顺序依次处理
+	IgnoreNotFound bool            // 没有订阅者时不返回 ErrEventNotFound
+}
 
-	return x
+// 订阅选项
+type SubscribeOptions struct {
+	Priority int  // 优先级, 越大越先处理, 相同优先级按订阅顺序处理
+	Once     bool // 只处理一次, 处理后自动取消订阅
 }
 
-func eventWorkerJob() bool {
-	if data := popEventQueue(); data != nil {
-		if e := Publish(data.Key, data.Data); e != nil && data.OnError != nil {
-			ulog.Warn("event worker publish %s: %s", data.Key, e)
-			data.OnError(e)
-			return true
-		}
-	}
+type eventSubscriber struct {
+	key      string
+	seq      uint64
+	opts     SubscribeOptions
+	handler  EventHandler[any]
+	accept   func(data any) bool // 是否处理该数据, 为空时处理所有数据
+	consumed atomic.Bool
+}
 
-	return false
+// 事件总线统计
+type EventBusStats struct {
+	Capacity  int   `json:"capacity"`  // 队列容量
+	Workers   int   `json:"workers"`   // 队列处理协程数量
+	Queued    int   `json:"queued"`    // 当前队列深度
+	Published int64 `json:"published"` // 已发布事件数量 (包含直接发布和队列发布)
+	Dropped   int64 `json:"dropped"`   // 队列已满被丢弃的事件数量
+	Failed    int64 `json:"failed"`    // 处理失败的事件数量
 }
 
-func eventWorker() {
-	for {
-		if eventWorkerJob() {
-			continue
+type EventBus struct {
+	event     *umap.Hmap[string, *umap.Hmap[string, *eventSubscriber]] // 精确匹配的事件订阅
+	pattern   *umap.Hmap[string, *umap.Hmap[string, *eventSubscriber]] // 通配符事件订阅
+	subscribe *sync.RWMutex                                            // 订阅锁
+	seq       atomic.Uint64                                            // 订阅序号
+	queue     []chan *eventQueueData                                   // 事件队列, 有序时每个处理协程一个队列
+	policy    QueueFullPolicy                                          // 队列已满时的处理策略
+	workers   int                                                      // 队列处理协程数量
+	ignore    bool                                                     // 没有订阅者时不返回错误
+	wg        *sync.WaitGroup                                          // 队列处理协程
+	closed    bool                                                     // 是否已经关闭队列
+	closeLock *sync.RWMutex                                            // 关闭锁, 防止向已关闭的队列发送
+	done      chan struct{}                                            // 开始关闭时关闭, 结束阻塞的入队
+	closeOnce *sync.Once
+
+	published atomic.Int64
+	dropped   atomic.Int64
+	failed    atomic.Int64
+}
+
+func NewEventBus(opts EventBusOptions) *EventBus {
+	if opts.Capacity < 1 {
+		opts.Capacity = eventQueueSize
+	}
+
+	if opts.Workers < 1 {
+		opts.Workers = eventQueueWorker
+	}
+
+	bus := &EventBus{
+		event:     umap.NewHmap[string, *umap.Hmap[string, *eventSubscriber]](),
+		pattern:   umap.NewHmap[string, *umap.Hmap[string, *eventSubscriber]](),
+		subscribe: &sync.RWMutex{},
+		policy:    opts.Policy,
+		workers:   opts.Workers,
+		ignore:    opts.IgnoreNotFound,
+		wg:        &sync.WaitGroup{},
+		closeLock: &sync.RWMutex{},
+		done:      make(chan struct{}),
+		closeOnce: &sync.Once{},
+	}
+
+	if opts.Ordered {
+		size := (opts.Capacity + opts.Workers - 1) / opts.Workers
+		for i := 0; i < bus.workers; i++ {
+			bus.queue = append(bus.queue, make(chan *eventQueueData, size))
 		}
+	} else {
+		bus.queue = []chan *eventQueueData{make(chan *eventQueueData, opts.Capacity)}
+	}
 
-		eventCond.L.Lock()
-		eventCond.Wait()
-		eventCond.L.Unlock()
+	for i := 0; i < bus.workers; i++ {
+		bus.wg.Add(1)
+		go bus.worker(bus.queue[i%len(bus.queue)])
 	}
+
+	return bus
+}
+
+// 全局事件总线, 全局事件函数都使用该总线
+func DefaultEventBus() *EventBus {
+	defaultEventBusOnce.Do(func() {
+		defaultEventBus = NewEventBus(EventBusOptions{})
+	})
+
+	return defaultEventBus
 }
 
-func init() {
-	for i := 0; i < eventQueueWorker; i++ {
-		go eventWorker()
+// 处理协程中的上下文, 值为所属的事件总线
+type eventWorkerKey struct{}
+
+func (bus *EventBus) worker(queue chan *eventQueueData) {
+	defer bus.wg.Done()
+
+	for data := range queue {
+		ctx := context.WithValue(data.Ctx, eventWorkerKey{}, bus)
+		if e := bus.Publish(ctx, data.Key, data.Data); e != nil && data.OnError != nil {
+			ulog.Warn("event worker publish %s: %s", data.Key, e)
+			data.OnError(e)
+		}
 	}
 }
 
-func generateKey(h *umap.Hmap[string, EventHandler[any]], maxRetries int) (string, error) {
+func generateKey(h *umap.Hmap[string, *eventSubscriber], maxRetries int) (string, error) {
 	for i := 0; i < maxRetries; i++ {
 		b := make([]byte, 64)
 		if _, e := rand.Read(b); e != nil {
@@ -114,79 +199,259 @@ func generateKey(h *umap.Hmap[string, EventHandler[any]], maxRetries int) (strin
 	return "", fmt.Errorf("failed to generate unique key, retries %d", maxRetries)
 }
 
-func Subscribe[T any](key EventKey, handler EventHandler[T]) (string, error) {
-	eventSubscribeRWMutex.Lock()
-	defer eventSubscribeRWMutex.Unlock()
+// 是否为通配符事件键, 以 . 分隔, * 匹配一段, # 匹配零或多段
+func isEventPattern(key string) bool {
+	for _, seg := range strings.Split(key, ".") {
+		if seg == "*" || seg == "#" {
+			return true
+		}
+	}
 
-	if _, ok := event.Load(key.String()); !ok {
-		event.Set(key.String(), umap.NewHmap[string, EventHandler[any]]())
+	return false
+}
+
+func matchEventKey(pattern, key []string) bool {
+	if len(pattern) < 1 {
+		return len(key) < 1
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(key); i++ {
+			if matchEventKey(pattern[1:], key[i:]) {
+				return true
+			}
+		}
+
+		return false
+	case "*":
+		return len(key) > 0 && matchEventKey(pattern[1:], key[1:])
+	default:
+		return len(key) > 0 && key[0] == pattern[0] && matchEventKey(pattern[1:], key[1:])
+	}
+}
+
+func (bus *EventBus) Subscribe(key EventKey, handler EventHandler[any]) (string, error) {
+	return bus.SubscribeWith(key, handler, SubscribeOptions{})
+}
+
+// 订阅一次, 处理后自动取消订阅
+func (bus *EventBus) SubscribeOnce(key EventKey, handler EventHandler[any]) (string, error) {
+	return bus.SubscribeWith(key, handler, SubscribeOptions{Once: true})
+}
+
+// 订阅事件
+// @param key 事件键, 可以使用通配符, 例如 user.* 或者 order.#
+// @param handler 处理函数
+// @param opts 订阅选项
+// @return 订阅者键, 用于取消订阅
+func (bus *EventBus) SubscribeWith(key EventKey, handler EventHandler[any], opts SubscribeOptions) (string, error) {
+	return bus.subscribeWith(key, handler, nil, opts)
+}
+
+func (bus *EventBus) subscribeWith(key EventKey, handler EventHandler[any], accept func(data any) bool, opts SubscribeOptions) (string, error) {
+	bus.subscribe.Lock()
+	defer bus.subscribe.Unlock()
+
+	m := bus.event
+	if isEventPattern(key.String()) {
+		m = bus.pattern
 	}
 
-	eh := event.Get(key.String())
+	if _, ok := m.Load(key.String()); !ok {
+		m.Set(key.String(), umap.NewHmap[string, *eventSubscriber]())
+	}
+
+	eh := m.Get(key.String())
 
 	kk, e := generateKey(eh, 12)
 	if e != nil {
 		return "", e
 	}
 
-	eh.Set(kk, func(ctx context.Context, data any) error {
-		if d, ok := data.(T); ok {
-			return handler(ctx, d)
+	eh.Set(kk, &eventSubscriber{
+		key:     kk,
+		seq:     bus.seq.Add(1),
+		opts:    opts,
+		handler: handler,
+		accept:  accept,
+	})
+
+	return kk, nil
+}
+
+// 匹配事件键的订阅者, 按优先级和订阅顺序排列
+func (bus *EventBus) subscribers(key EventKey) []*eventSubscriber {
+	list := []*eventSubscriber{}
+	collect := func(k string, v *eventSubscriber) bool {
+		list = append(list, v)
+		return true
+	}
+
+	if v, ok := bus.event.Load(key.String()); ok {
+		v.Range(collect)
+	}
+
+	segments := strings.Split(key.String(), ".")
+	bus.pattern.Range(func(pattern string, v *umap.Hmap[string, *eventSubscriber]) bool {
+		if matchEventKey(strings.Split(pattern, "."), segments) {
+			v.Range(collect)
 		}
 
-		return nil
+		return true
 	})
 
-	return kk, nil
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].opts.Priority != list[j].opts.Priority {
+			return list[i].opts.Priority > list[j].opts.Priority
+		}
+
+		return list[i].seq < list[j].seq
+	})
+
+	return list
 }
 
-func Publish[T any](key EventKey, data T) error {
-	eventSubscribeRWMutex.RLock()
-	defer eventSubscribeRWMutex.RUnlock()
+func (bus *EventBus) removeSubscriber(sub *eventSubscriber) {
+	for _, m := range []*umap.Hmap[string, *umap.Hmap[string, *eventSubscriber]]{bus.event, bus.pattern} {
+		m.Range(func(k string, v *umap.Hmap[string, *eventSubscriber]) bool {
+			if s, ok := v.Load(sub.key); ok && s == sub {
+				v.Delete(sub.key)
+				return false
+			}
+
+			return true
+		})
+	}
+}
+
+// 同步发布事件, 在当前协程中按优先级依次调用所有匹配的订阅者
+// @description 调用前复制订阅者列表, 处理函数中可以订阅和取消订阅
+func (bus *EventBus) Publish(ctx context.Context, key EventKey, data any) error {
+	bus.subscribe.RLock()
+	list := bus.subscribers(key)
+	_, subscribed := bus.event.Load(key.String())
+	bus.subscribe.RUnlock()
+
+	bus.published.Add(1)
+	errorsPool, found := error(nil), false
+
+	for _, sub := range list {
+		// 数据类型不匹配时不处理, 只处理一次的订阅也不会被消耗
+		if sub.accept != nil && !sub.accept(data) {
+			found = true
+			continue
+		}
 
-	ctx, errorsPool := context.Background(), error(nil)
+		if sub.opts.Once {
+			if !sub.consumed.CompareAndSwap(false, true) {
+				continue
+			}
+
+			bus.subscribe.Lock()
+			bus.removeSubscriber(sub)
+			bus.subscribe.Unlock()
+		}
 
-	if v, ok := event.Load(key.String()); ok {
-		v.Range(func(handlerKey string, handler EventHandler[any]) bool {
+		found = true
+		func() {
 			defer func() {
 				if r := recover(); r != nil {
 					errorsPool = errors.Join(errorsPool, fmt.Errorf("recover: %v", r))
 				}
 			}()
 
-			if e := handler(ctx, data); e != nil {
+			if e := sub.handler(ctx, data); e != nil {
 				errorsPool = errors.Join(errorsPool, e)
 			}
+		}()
+	}
 
-			return true
-		})
+	// 订阅过的事件键即使订阅者已经全部取消也不返回 ErrEventNotFound
+	if !found {
+		if bus.ignore || subscribed {
+			return nil
+		}
+
+		return ErrEventNotFound
+	}
 
-		return errorsPool
+	if errorsPool != nil {
+		bus.failed.Add(1)
 	}
 
-	return errors.New("event not found")
+	return errorsPool
 }
 
-func PublishQueue[T any](key EventKey, data T, onError func(error)) {
-	eventQueueMutex.Lock()
-	defer eventQueueMutex.Unlock()
+// 异步发布事件
+// @description 事件进入队列后由处理协程发布, 处理函数收到的上下文保留 ctx 的值但不会随 ctx 取消;
+// 在处理函数中向同一个总线发布时不会阻塞, 队列已满返回 ErrEventQueueFull, 避免处理协程互相等待
+// @param ctx 上下文, 阻塞策略下取消或总线关闭时放弃入队
+// @param onError 处理失败时的回调
+// @return 入队错误
+func (bus *EventBus) PublishQueue(ctx context.Context, key EventKey, data any, onError func(error)) error {
+	bus.closeLock.RLock()
+	defer bus.closeLock.RUnlock()
+
+	select {
+	case <-bus.done:
+		return ErrEventBusClosed
+	default:
+	}
 
-	eventQueue = append(eventQueue, &eventQueueData{
+	d := &eventQueueData{
+		Ctx:     context.WithoutCancel(ctx),
 		Key:     key,
 		Data:    data,
 		OnError: onError,
-	})
+	}
+
+	queue := bus.queue[0]
+	if len(bus.queue) > 1 {
+		h := fnv.New32a()
+		h.Write([]byte(key.String()))
+		queue = bus.queue[h.Sum32()%uint32(len(bus.queue))]
+	}
+
+	select {
+	case queue <- d:
+		return nil
+	default:
+	}
 
-	eventCond.Signal()
+	switch {
+	case bus.policy == QueueDrop:
+		bus.dropped.Add(1)
+		return nil
+	case bus.policy == QueueError, ctx.Value(eventWorkerKey{}) == bus:
+		bus.dropped.Add(1)
+		return ErrEventQueueFull
+	}
+
+	select {
+	case queue <- d:
+		return nil
+	case <-ctx.Done():
+		bus.dropped.Add(1)
+		return ctx.Err()
+	case <-bus.done:
+		bus.dropped.Add(1)
+		return ErrEventBusClosed
+	}
 }
 
-func UnSubscribe(key EventKey, handlerKey ...string) error {
-	eventSubscribeRWMutex.Lock()
-	defer eventSubscribeRWMutex.Unlock()
+func (bus *EventBus) UnSubscribe(key EventKey, handlerKey ...string) error {
+	bus.subscribe.Lock()
+	defer bus.subscribe.Unlock()
+
+	m := bus.event
+	if isEventPattern(key.String()) {
+		m = bus.pattern
+	}
 
-	if v, ok := event.Load(key.String()); ok {
+	if v, ok := m.Load(key.String()); ok {
 		if len(handlerKey) < 1 {
-			event.Delete(key.String())
+			m.Delete(key.String())
 			return nil
 		}
 
@@ -197,3 +462,89 @@ func UnSubscribe(key EventKey, handlerKey ...string) error {
 
 	return nil
 }
+
+func (bus *EventBus) Stats() EventBusStats {
+	stats := EventBusStats{
+		Workers:   bus.workers,
+		Published: bus.published.Load(),
+		Dropped:   bus.dropped.Load(),
+		Failed:    bus.failed.Load(),
+	}
+
+	for _, queue := range bus.queue {
+		stats.Capacity += cap(queue)
+		stats.Queued += len(queue)
+	}
+
+	return stats
+}
+
+// 关闭事件总线
+// @description 不再接受新的队列事件, 阻塞中的入队返回 ErrEventBusClosed, 等待队列中剩余的事件处理完成
+// @param ctx 上下文, 取消时不再等待, 队列在后台继续关闭
+// @return 等待超时时返回剩余事件数量
+func (bus *EventBus) Close(ctx context.Context) error {
+	bus.closeOnce.Do(func() {
+		close(bus.done)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		bus.closeLock.Lock()
+		if !bus.closed {
+			bus.closed = true
+			for _, queue := range bus.queue {
+				close(queue)
+			}
+		}
+		bus.closeLock.Unlock()
+
+		bus.wg.Wait()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("event bus drain: %d events left: %w", bus.Stats().Queued, ctx.Err())
+	}
+}
+
+// 订阅指定类型的事件, 数据类型不匹配的事件会被忽略
+func SubscribeOn[T any](bus *EventBus, key EventKey, handler EventHandler[T], opts ...SubscribeOptions) (string, error) {
+	o := SubscribeOptions{}
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	return bus.subscribeWith(key, func(ctx context.Context, data any) error {
+		return handler(ctx, data.(T))
+	}, func(data any) bool {
+		_, ok := data.(T)
+		return ok
+	}, o)
+}
+
+func Subscribe[T any](key EventKey, handler EventHandler[T], opts ...SubscribeOptions) (string, error) {
+	return SubscribeOn(DefaultEventBus(), key, handler, opts...)
+}
+
+func SubscribeOnce[T any](key EventKey, handler EventHandler[T]) (string, error) {
+	return SubscribeOn(DefaultEventBus(), key, handler, SubscribeOptions{Once: true})
+}
+
+func Publish[T any](key EventKey, data T) error {
+	return DefaultEventBus().Publish(context.Background(), key, data)
+}
+
+func PublishQueue[T any](key EventKey, data T, onError func(error)) {
+	if e := DefaultEventBus().PublishQueue(context.Background(), key, data, onError); e != nil && onError != nil {
+		onError(e)
+	}
+}
+
+func UnSubscribe(key EventKey, handlerKey ...string) error {
+	return DefaultEventBus().UnSubscribe(key, handlerKey...)
+}
@@ -0,0 +1,248 @@
+package uboot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type eventTestCtxKey struct{}
+
+func TestEventBus(t *testing.T) {
+	key := NewEventKey("test")
+
+	t.Run("Policy", func(t *testing.T) {
+		bus := NewEventBus(EventBusOptions{Capacity: 1, Workers: 1, Policy: QueueError})
+		release := make(chan struct{})
+		SubscribeOn(bus, key, func(ctx context.Context, data int) error {
+			<-release
+			return nil
+		})
+
+		bus.PublishQueue(context.Background(), key, 1, nil) // 处理中
+		time.Sleep(10 * time.Millisecond)
+		bus.PublishQueue(context.Background(), key, 2, nil) // 队列中
+
+		if e := bus.PublishQueue(context.Background(), key, 3, nil); !errors.Is(e, ErrEventQueueFull) {
+			t.Fatalf("expected queue full, got %v", e)
+		}
+
+		if s := bus.Stats(); s.Dropped != 1 || s.Queued != 1 {
+			t.Fatalf("stats: %+v", s)
+		}
+
+		close(release)
+		if e := bus.Close(context.Background()); e != nil {
+			t.Fatal(e)
+		}
+
+		if e := bus.PublishQueue(context.Background(), key, 4, nil); !errors.Is(e, ErrEventBusClosed) {
+			t.Fatalf("expected closed, got %v", e)
+		}
+	})
+
+	t.Run("Drain", func(t *testing.T) {
+		bus := NewEventBus(EventBusOptions{Workers: 2})
+		handled := &atomic.Int32{}
+		SubscribeOn(bus, key, func(ctx context.Context, data string) error {
+			if ctx.Value(eventTestCtxKey{}) != "value" {
+				t.Error("context value lost")
+			}
+
+			time.Sleep(time.Millisecond)
+			handled.Add(1)
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), eventTestCtxKey{}, "value"))
+		for i := 0; i < 20; i++ {
+			bus.PublishQueue(ctx, key, "data", nil)
+		}
+		cancel()
+
+		if e := bus.Close(context.Background()); e != nil {
+			t.Fatal(e)
+		}
+
+		if n := handled.Load(); n != 20 {
+			t.Fatalf("handled: %d", n)
+		}
+	})
+}
+
+func TestEventMatch(t *testing.T) {
+	bus := NewEventBus(EventBusOptions{Workers: 1, IgnoreNotFound: true})
+	defer bus.Close(context.Background())
+
+	got := []string{}
+	record := func(name string) EventHandler[string] {
+		return func(ctx context.Context, data string) error {
+			got = append(got, name+":"+data)
+			return nil
+		}
+	}
+
+	SubscribeOn(bus, NewEventKey("user.created"), record("exact"))
+	SubscribeOn(bus, NewEventKey("user.*"), record("star"), SubscribeOptions{Priority: 10})
+	SubscribeOn(bus, NewEventKey("order.#"), record("hash"))
+	SubscribeOn(bus, NewEventKey("user.created"), record("once"), SubscribeOptions{Once: true})
+
+	// 类型不匹配的数据不会消耗只处理一次的订阅
+	bus.Publish(context.Background(), NewEventKey("user.created"), 0)
+	bus.Publish(context.Background(), NewEventKey("user.created"), "1")
+	bus.Publish(context.Background(), NewEventKey("user.created"), "2")
+	bus.Publish(context.Background(), NewEventKey("user.created.extra"), "3")
+	bus.Publish(context.Background(), NewEventKey("order"), "4")
+	bus.Publish(context.Background(), NewEventKey("order.paid.refund"), "5")
+
+	want := "star:1,exact:1,once:1,star:2,exact:2,hash:4,hash:5"
+	if s := strings.Join(got, ","); s != want {
+		t.Fatalf("got %s, want %s", s, want)
+	}
+
+	if e := bus.Publish(context.Background(), NewEventKey("nobody"), "6"); e != nil {
+		t.Fatalf("ignore not found: %v", e)
+	}
+}
+
+func TestEventUnSubscribe(t *testing.T) {
+	bus := NewEventBus(EventBusOptions{Workers: 1})
+	defer bus.Close(context.Background())
+
+	key := NewEventKey("user.deleted")
+	if e := bus.Publish(context.Background(), key, "1"); !errors.Is(e, ErrEventNotFound) {
+		t.Fatalf("expected not found, got %v", e)
+	}
+
+	// 订阅者全部取消后与之前的行为一致, 不返回 ErrEventNotFound
+	handlerKey, _ := SubscribeOn(bus, key, func(ctx context.Context, data string) error { return nil })
+	bus.UnSubscribe(key, handlerKey)
+	if e := bus.Publish(context.Background(), key, "2"); e != nil {
+		t.Fatalf("unsubscribed key: %v", e)
+	}
+
+	SubscribeOn(bus, key, func(ctx context.Context, data string) error { return nil }, SubscribeOptions{Once: true})
+	bus.Publish(context.Background(), key, "3")
+	if e := bus.Publish(context.Background(), key, "4"); e != nil {
+		t.Fatalf("consumed once: %v", e)
+	}
+}
+
+func TestEventOrdered(t *testing.T) {
+	bus := NewEventBus(EventBusOptions{Workers: 8, Ordered: true})
+
+	last, mu := map[string]int{}, &sync.Mutex{}
+	for _, k := range []string{"a", "b", "c"} {
+		k := k
+		SubscribeOn(bus, NewEventKey(k), func(ctx context.Context, n int) error {
+			time.Sleep(time.Duration(n%3) * time.Microsecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if last[k] != n-1 {
+				t.Errorf("%s out of order: %d after %d", k, n, last[k])
+			}
+			last[k] = n
+			return nil
+		})
+	}
+
+	for i := 1; i <= 100; i++ {
+		for _, k := range []string{"a", "b", "c"} {
+			bus.PublishQueue(context.Background(), NewEventKey(k), i, nil)
+		}
+	}
+
+	if e := bus.Close(context.Background()); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestEventDeadlock(t *testing.T) {
+	key := NewEventKey("test")
+
+	t.Run("CloseBlocked", func(t *testing.T) {
+		bus := NewEventBus(EventBusOptions{Capacity: 1, Workers: 1})
+		release := make(chan struct{})
+		defer close(release)
+		SubscribeOn(bus, key, func(ctx context.Context, data int) error {
+			<-release
+			return nil
+		})
+
+		bus.PublishQueue(context.Background(), key, 1, nil)
+		time.Sleep(10 * time.Millisecond)
+		bus.PublishQueue(context.Background(), key, 2, nil)
+
+		blocked := make(chan error, 1)
+		go func() {
+			blocked <- bus.PublishQueue(context.Background(), key, 3, nil)
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if e := bus.Close(ctx); !errors.Is(e, context.DeadlineExceeded) || time.Since(start) > time.Second {
+			t.Fatalf("close: %v %s", e, time.Since(start))
+		}
+
+		if e := <-blocked; !errors.Is(e, ErrEventBusClosed) {
+			t.Fatalf("blocked publish: %v", e)
+		}
+	})
+
+	t.Run("PublishFromWorker", func(t *testing.T) {
+		bus := NewEventBus(EventBusOptions{Capacity: 1, Workers: 1})
+		errs := make(chan error, 4)
+		SubscribeOn(bus, key, func(ctx context.Context, data int) error {
+			if data == 1 {
+				errs <- bus.PublishQueue(ctx, key, 2, nil)
+				errs <- bus.PublishQueue(ctx, key, 3, nil)
+			}
+			return nil
+		})
+
+		bus.PublishQueue(context.Background(), key, 1, nil)
+
+		for _, want := range []error{nil, ErrEventQueueFull} {
+			select {
+			case e := <-errs:
+				if !errors.Is(e, want) {
+					t.Fatalf("expected %v, got %v", want, e)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("worker deadlocked")
+			}
+		}
+
+		bus.Close(context.Background())
+	})
+
+	t.Run("SubscribeInHandler", func(t *testing.T) {
+		bus := NewEventBus(EventBusOptions{})
+		defer bus.Close(context.Background())
+
+		SubscribeOn(bus, key, func(ctx context.Context, data int) error {
+			_, e := SubscribeOn(bus, NewEventKey("other"), func(ctx context.Context, data int) error { return nil })
+			return e
+		})
+
+		done := make(chan error, 1)
+		go func() { done <- bus.Publish(context.Background(), key, 1) }()
+
+		select {
+		case e := <-done:
+			if e != nil {
+				t.Fatal(e)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("subscribe in handler deadlocked")
+		}
+	})
+}
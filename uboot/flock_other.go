@@ -0,0 +1,18 @@
+//go:build !unix
+
+package uboot
+
+import (
+	"errors"
+	"os"
+)
+
+var errFlockUnsupported = errors.New("file lock not supported on this platform")
+
+func lockFile(f *os.File) (bool, error) {
+	return false, errFlockUnsupported
+}
+
+func unlockFile(f *os.File) error {
+	return errFlockUnsupported
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package uboot
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// 尝试获取文件排他锁, 不阻塞
+// @return 是否获取成功
+func lockFile(f *os.File) (bool, error) {
+	e := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(e, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+
+	return e == nil, e
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,264 @@
+package uboot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"uw/ulog"
+)
+
+type RestartMode uint8
+
+const (
+	RestartOnError RestartMode = iota // 返回错误或 panic 时重启 (默认)
+	RestartOnPanic                    // 仅 panic 时重启
+	RestartAlways                     // 无论如何退出都重启
+)
+
+type FailAction uint8
+
+const (
+	FailIgnore   FailAction = iota // 忽略, 仅标记模块失败 (默认)
+	FailShutdown                   // 关闭 Boot
+	FailCrash                      // 崩溃整个进程
+)
+
+// 守护模块重启策略
+type RestartPolicy struct {
+	Mode        RestartMode   // 重启条件
+	Interval    time.Duration // 首次重启间隔, 为 0 时使用 Boot.DaemonRestartAfter
+	MaxInterval time.Duration // 最大重启间隔, 为 0 时为 5 分钟 (首次间隔更大时为首次间隔)
+	Multiplier  float64       // 每次重启间隔的倍数, 小于等于 1 时固定间隔
+	ResetAfter  time.Duration // 运行超过该时长视为稳定, 下次重启从首次间隔开始, 为 0 时为最大重启间隔
+	Jitter      float64       // 随机抖动比例 (0-1), 间隔在 ±Jitter 范围内浮动
+	MaxRestarts int           // 时间窗口内的最大重启次数, 为 0 时不限制
+	Window      time.Duration // 统计重启次数的时间窗口, 为 0 时统计全部
+	OnFailed    FailAction    // 超过最大重启次数后的动作
+}
+
+const defaultRestartMaxInterval = 5 * time.Minute
+
+// 设置守护模块的重启策略
+func (u *UintAgent) Restart(p RestartPolicy) *UintAgent {
+	u.restart = p
+	return u
+}
+
+// 是否需要重启
+func (p RestartPolicy) shouldRestart(e error) bool {
+	switch p.Mode {
+	case RestartAlways:
+		return true
+	case RestartOnPanic:
+		var pe *PanicError
+		return errors.As(e, &pe)
+	default:
+		return e != nil
+	}
+}
+
+// 最大重启间隔
+func (p RestartPolicy) maxInterval(interval time.Duration) time.Duration {
+	if p.MaxInterval > 0 {
+		return p.MaxInterval
+	}
+
+	if p.Interval > 0 {
+		interval = p.Interval
+	}
+
+	return max(defaultRestartMaxInterval, interval)
+}
+
+// 计算第 n 次 (从 0 开始) 重启的间隔
+// @description 在转换为 time.Duration 之前限制到最大间隔, 避免重启次数很多时溢出
+func (p RestartPolicy) backoff(n int, interval time.Duration) time.Duration {
+	if p.Interval > 0 {
+		interval = p.Interval
+	}
+
+	maxInterval := float64(p.maxInterval(interval))
+
+	d := float64(interval)
+	if p.Multiplier > 1 {
+		d *= math.Pow(p.Multiplier, float64(n))
+	}
+
+	d = math.Min(d, maxInterval)
+
+	if p.Jitter > 0 {
+		d += d * math.Min(p.Jitter, 1) * (rand.Float64()*2 - 1)
+	}
+
+	return time.Duration(math.Max(d, 0))
+}
+
+// 守护模块重启计数
+type restartCounter struct {
+	policy   RestartPolicy
+	history  []time.Time // 窗口内的重启时间, 只在设置了 Window 时使用
+	total    int         // 没有设置 Window 时的重启总数
+	attempts int         // 上次稳定运行之后的重启次数, 用于计算重启间隔
+}
+
+// 记录一次重启
+// @return 窗口内的重启次数 (不含本次), 是否超过最大重启次数
+func (rc *restartCounter) add(now time.Time) (int, bool) {
+	n := rc.total
+	if rc.policy.Window > 0 {
+		i := 0
+		for ; i < len(rc.history) && now.Sub(rc.history[i]) > rc.policy.Window; i++ {
+		}
+		rc.history = rc.history[i:]
+		n = len(rc.history)
+	}
+
+	if rc.policy.MaxRestarts > 0 && n >= rc.policy.MaxRestarts {
+		return n, true
+	}
+
+	if rc.policy.Window > 0 {
+		rc.history = append(rc.history, now)
+	} else {
+		rc.total++
+	}
+
+	return n, false
+}
+
+// 本次运行的时长, 超过 ResetAfter 时重置重启间隔
+func (rc *restartCounter) ran(d, interval time.Duration) {
+	resetAfter := rc.policy.ResetAfter
+	if resetAfter <= 0 {
+		resetAfter = rc.policy.maxInterval(interval)
+	}
+
+	if d >= resetAfter {
+		rc.attempts = 0
+	}
+}
+
+// 下一次重启的间隔
+func (rc *restartCounter) next(interval time.Duration) time.Duration {
+	d := rc.policy.backoff(rc.attempts, interval)
+	rc.attempts++
+	return d
+}
+
+func (b *Boot) runDaemon(u *UintAgent) {
+	u.recover = false
+	rc := &restartCounter{policy: u.restart}
+
+	if u.singleton != "" {
+		release, ok := b.acquireLeader(u)
+		if !ok {
+			return
+		}
+
+		defer release()
+	}
+
+	for {
+		c := b.newContext(u)
+		u.current.Store(c)
+		start := b.now()
+		e := u.run(c)
+		u.current.Store(nil)
+		rc.ran(b.now().Sub(start), b.daemonRestartAfter)
+
+		if b.stopping() {
+			if e != nil {
+				c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "uboot shutting down, daemon uint will not restart"))
+			}
+			return
+		}
+
+		if u.forceRestart.Swap(false) {
+			u.status.set(UintRestarting, nil)
+			c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "restart daemon uint by request"))
+			continue
+		}
+
+		if !u.restart.shouldRestart(e) {
+			if e != nil {
+				c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "daemon uint exit: %s"), e)
+			}
+			return
+		}
+
+		n, exceeded := rc.add(b.now())
+		if exceeded {
+			b.daemonFailed(c, fmt.Errorf("restarted %d times: %w", n, e))
+			return
+		}
+
+		reason := "exit"
+		if e != nil {
+			reason = e.Error()
+		}
+
+		u.status.set(UintRestarting, nil)
+
+		d := rc.next(b.daemonRestartAfter)
+		c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "daemon uint %s, restart after %s"), reason, d)
+
+		t := b.clock.NewTimer(d)
+		select {
+		case <-t.C():
+		case <-b.ctx.Done():
+			t.Stop()
+			return
+		}
+
+		c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "restart daemon uint"))
+	}
+}
+
+func (b *Boot) daemonFailed(c *Context, e error) {
+	c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "daemon uint failed: %s"), e)
+	c.u.status.set(UintFailed, e)
+
+	switch c.u.restart.OnFailed {
+	case FailShutdown:
+		go b.Shutdown(context.Background())
+	case FailCrash:
+		b.fail(fmt.Errorf("[%s] daemon uint failed: %w", c.u.name, e))
+	}
+}
+
+// 重启守护模块
+// @description 取消模块当前运行的上下文, 处理函数返回后立即重新运行, 不受重启策略限制,
+// 处理函数需要监听 Context 的取消
+// @param name 模块名称
+func (b *Boot) Restart(name string) error {
+	if b.stopping() {
+		return errors.New("uboot is shutting down")
+	}
+
+	found, restarted := false, false
+	for _, u := range b.phases()[UintDaemon] {
+		if u.name != name {
+			continue
+		}
+
+		found = true
+		if c := u.current.Load(); c != nil {
+			u.forceRestart.Store(true)
+			c.Cancel()
+			restarted = true
+		}
+	}
+
+	switch {
+	case !found:
+		return fmt.Errorf("daemon uint %s not found", name)
+	case !restarted:
+		return fmt.Errorf("daemon uint %s not running", name)
+	}
+
+	return nil
+}
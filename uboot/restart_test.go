@@ -0,0 +1,107 @@
+package uboot
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRestartPolicy(t *testing.T) {
+	t.Run("Backoff", func(t *testing.T) {
+		p := RestartPolicy{Interval: 10 * time.Millisecond, MaxInterval: 50 * time.Millisecond, Multiplier: 2}
+		for n, want := range []time.Duration{10, 20, 40, 50, 50} {
+			if d := p.backoff(n, time.Second); d != want*time.Millisecond {
+				t.Fatalf("backoff %d: %s", n, d)
+			}
+		}
+
+		if d := (RestartPolicy{}).backoff(3, time.Second); d != time.Second {
+			t.Fatalf("default backoff: %s", d)
+		}
+
+		// 没有最大间隔时不能溢出
+		p = RestartPolicy{Interval: time.Second, Multiplier: 2, Jitter: 0.5}
+		for _, n := range []int{40, 100, 2000} {
+			if d := p.backoff(n, time.Second); d <= 0 || d > defaultRestartMaxInterval*3/2 {
+				t.Fatalf("backoff %d: %s", n, d)
+			}
+		}
+	})
+
+	t.Run("Counter", func(t *testing.T) {
+		rc := &restartCounter{policy: RestartPolicy{Interval: 10 * time.Millisecond, Multiplier: 2, MaxRestarts: 100}}
+		now := time.Now()
+		for i := 0; i < 100; i++ {
+			if _, exceeded := rc.add(now); exceeded {
+				t.Fatalf("exceeded at %d", i)
+			}
+		}
+
+		if n, exceeded := rc.add(now); !exceeded || n != 100 || len(rc.history) != 0 {
+			t.Fatalf("no window: %d %v %d", n, exceeded, len(rc.history))
+		}
+
+		rc.next(0)
+		rc.next(0)
+		if d := rc.next(0); d != 40*time.Millisecond {
+			t.Fatalf("third restart: %s", d)
+		}
+
+		rc.ran(time.Second, 0)
+		if d := rc.next(0); d != 80*time.Millisecond {
+			t.Fatalf("unstable run reset: %s", d)
+		}
+
+		rc.ran(defaultRestartMaxInterval, 0)
+		if d := rc.next(0); d != 10*time.Millisecond {
+			t.Fatalf("stable run not reset: %s", d)
+		}
+	})
+
+	t.Run("Mode", func(t *testing.T) {
+		pe := &PanicError{Value: "boom"}
+		if (RestartPolicy{Mode: RestartOnPanic}).shouldRestart(errors.New("e")) {
+			t.Fatal("on panic restarted by error")
+		}
+		if !(RestartPolicy{Mode: RestartOnPanic}).shouldRestart(pe) {
+			t.Fatal("on panic not restarted by panic")
+		}
+		if (RestartPolicy{}).shouldRestart(nil) {
+			t.Fatal("on error restarted by exit")
+		}
+		if !(RestartPolicy{Mode: RestartAlways}).shouldRestart(nil) {
+			t.Fatal("always not restarted by exit")
+		}
+	})
+
+	t.Run("MaxRestarts", func(t *testing.T) {
+		runs := &atomic.Int32{}
+		b := NewBoot().Signals().Register(
+			Uint("daemon", UintDaemon, func(c *Context) error {
+				runs.Add(1)
+				panic("boom")
+			}).Restart(RestartPolicy{
+				Interval:    time.Millisecond,
+				MaxRestarts: 3,
+				Window:      time.Minute,
+			}),
+		)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			b.Start()
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("daemon not failed")
+		}
+
+		if n := runs.Load(); n != 4 {
+			t.Fatalf("runs: %d", n)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package uboot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"uw/ulog"
+)
+
+// 是否已经开始关闭
+func (b *Boot) stopping() bool {
+	return b.ctx.Err() != nil
+}
+
+// 监听关闭信号, 第一次收到信号时开始关闭, 第二次收到信号时强制退出
+// @return 关闭该 channel 以停止监听
+func (b *Boot) notifySignal() chan struct{} {
+	sc, stop := make(chan os.Signal, 2), make(chan struct{})
+	signal.Notify(sc, b.signals...)
+
+	go func() {
+		defer signal.Stop(sc)
+
+		select {
+		case <-stop:
+			return
+		case s := <-sc:
+			b.printf(ulog.SetANSI(ulog.ANSI.Magenta, "receive signal %s, shutdown"), s)
+			go b.Shutdown(context.Background())
+		}
+
+		select {
+		case <-stop:
+		case s := <-sc:
+			b.printf(ulog.SetANSI(ulog.ANSI.Magenta, "receive signal %s again, force exit"), s)
+			os.Exit(1)
+		}
+	}()
+
+	return stop
+}
+
+// 关闭 Boot
+// @description 取消所有模块的上下文, 然后按依赖/注册顺序的逆序运行已启动模块的停止钩子,
+// 每个钩子受单个模块超时和总超时限制, 重复调用会返回第一次关闭的结果
+// @param ctx 上下文, 取消时放弃剩余的停止钩子
+// @return 所有停止失败的模块错误
+func (b *Boot) Shutdown(ctx context.Context) error {
+	b.shutdownOnce.Do(func() {
+		defer close(b.shutdownDone)
+		b.shutdownErr = b.shutdown(ctx)
+	})
+
+	return b.shutdownErr
+}
+
+func (b *Boot) shutdown(ctx context.Context) error {
+	b.printf(ulog.SetANSI(ulog.ANSI.Bold, "uboot shutdown"))
+	b.ctx.Cancel()
+
+	if b.shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.shutdownTimeout)
+		defer cancel()
+	}
+
+	failed, errs := []string{}, error(nil)
+
+	list := b.stopOrder()
+	for _, u := range list {
+		switch u.status.get() {
+		case UintStarting, UintRunning, UintRestarting:
+			u.status.set(UintStopping, nil)
+		}
+	}
+
+	for i := len(list) - 1; i >= 0; i-- {
+		u := list[i]
+		if state := u.status.get(); u.onStop == nil || state == UintPending || state == UintStandby {
+			continue
+		}
+
+		if e := b.stopUint(ctx, u); e != nil {
+			u.status.set(UintFailed, e)
+			failed = append(failed, u.name)
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", u.name, e))
+		}
+	}
+
+	if len(failed) > 0 {
+		b.printf(ulog.SetANSI(ulog.ANSI.Magenta, "uint stop failed: %s"), strings.Join(failed, ", "))
+	}
+
+	b.eventsLock.Lock()
+	events := b.events
+	b.eventsLock.Unlock()
+
+	if events != nil {
+		if e := events.Close(ctx); e != nil {
+			b.printf(ulog.SetANSI(ulog.ANSI.Magenta, "close event bus error: %s"), e)
+			errs = errors.Join(errs, e)
+		}
+	}
+
+	b.printf(ulog.SetANSI(ulog.ANSI.Bold, "uboot shutdown done"))
+
+	// 异步日志在进程退出前写完
+	ulog.Flush()
+	return errs
+}
+
+func (b *Boot) stopUint(ctx context.Context, u *UintAgent) (e error) {
+	timeout := b.stopTimeout
+	if u.stopTimeout > 0 {
+		timeout = u.stopTimeout
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	c := b.newContextWithParent(ctx, u)
+	defer c.Cancel()
+
+	c.Printf("uint stopping")
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("stop panic: %v", r)
+			}
+		}()
+
+		done <- u.onStop(c)
+	}()
+
+	select {
+	case e = <-done:
+	case <-c.Context().Done():
+		e = fmt.Errorf("stop timeout: %w", c.Context().Err())
+	}
+
+	if e != nil {
+		c.Printf(ulog.SetANSI(ulog.ANSI.Magenta, "uint stop error: %s"), e)
+		return e
+	}
+
+	c.Printf("uint stopped")
+	return nil
+}
+
+// 所有模块的启动顺序, 依赖总是排在被依赖模块之前
+func (b *Boot) stopOrder() []*UintAgent {
+	g := b.dependGraph()
+
+	list, visited := []*UintAgent{}, map[string]bool{}
+
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+
+		visited[name] = true
+		for _, dep := range g.edges[name] {
+			visit(dep)
+		}
+
+		list = append(list, g.units[name]...)
+	}
+
+	for _, name := range g.order {
+		visit(name)
+	}
+
+	return list
+}
@@ -0,0 +1,60 @@
+package uboot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBootShutdown(t *testing.T) {
+	order, mu := []string{}, &sync.Mutex{}
+	stop := func(c *Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, c.Name())
+		return nil
+	}
+	wait := func(c *Context) error {
+		<-c.Context().Done()
+		return nil
+	}
+
+	b := NewBoot().Signals().StopTimeout(50*time.Millisecond).Register(
+		Uint("db", UintFront, func(c *Context) error { return nil }).OnStop(stop),
+		Uint("http", UintBackground, wait).DependsOn("db").OnStop(stop),
+		Uint("worker", UintDaemon, wait).DependsOn("db").OnStop(stop),
+		Uint("slow", UintDaemon, wait).OnStop(func(c *Context) error {
+			<-c.Context().Done()
+			return nil
+		}),
+		Uint("broken", UintDaemon, wait).OnStop(func(c *Context) error {
+			return errors.New("broken")
+		}),
+	)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Start()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	e := b.Shutdown(context.Background())
+	if e == nil || !strings.Contains(e.Error(), "slow") || !strings.Contains(e.Error(), "broken") {
+		t.Fatalf("expected slow and broken stop errors, got %v", e)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("start not returned after shutdown")
+	}
+
+	if s := strings.Join(order, ","); s != "worker,http,db" {
+		t.Fatalf("stop order: %s", s)
+	}
+}
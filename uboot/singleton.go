@@ -0,0 +1,85 @@
+package uboot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var defaultSingletonRetry = time.Second // 备用实例尝试获取锁的时间间隔
+
+// 跨进程单例, 只能用于守护模块
+// @description 启动前获取锁文件的 flock 排他锁, 获取失败时作为备用实例等待,
+// 持有锁的进程退出 (或者模块不再重启) 后自动接管. 重启期间不会释放锁
+// @param lockName 锁文件路径, 不包含路径分隔符时为 os.TempDir() 下的 <lockName>.lock
+func (u *UintAgent) Singleton(lockName string) *UintAgent {
+	if filepath.Base(lockName) == lockName {
+		lockName = filepath.Join(os.TempDir(), lockName+".lock")
+	}
+
+	u.singleton = lockName
+	return u
+}
+
+// 是否为领导者, 跨进程单例模块持有锁时返回 true, 非单例模块总是返回 true
+func (c *Context) Leader() bool {
+	return c.u.singleton == "" || c.u.leader.Load()
+}
+
+// 获取单例锁, 获取失败时每隔 defaultSingletonRetry 重试, 直到成功或者 Boot 关闭
+// @return 释放锁, 是否获取成功
+func (b *Boot) acquireLeader(u *UintAgent) (func(), bool) {
+	c := b.newContext(u)
+
+	f, e := os.OpenFile(u.singleton, os.O_CREATE|os.O_RDWR, 0o644)
+	if e != nil {
+		b.daemonFailed(c, fmt.Errorf("open singleton lock: %w", e))
+		return nil, false
+	}
+
+	standby := false
+	for {
+		ok, e := lockFile(f)
+		if e != nil {
+			f.Close()
+			b.daemonFailed(c, fmt.Errorf("singleton lock: %w", e))
+			return nil, false
+		}
+
+		if ok {
+			break
+		}
+
+		if !standby {
+			standby = true
+			u.status.set(UintStandby, nil)
+			c.Printf("singleton lock held by other process, standby: %s", u.singleton)
+		}
+
+		t := b.clock.NewTimer(defaultSingletonRetry)
+		select {
+		case <-t.C():
+		case <-b.ctx.Done():
+			t.Stop()
+			f.Close()
+			return nil, false
+		}
+	}
+
+	// 记录持有锁的进程, 方便排查
+	if e := f.Truncate(0); e == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	u.leader.Store(true)
+	c.Printf("singleton lock acquired, leader: %s", u.singleton)
+
+	return func() {
+		u.leader.Store(false)
+		unlockFile(f)
+		f.Close()
+		c.Printf("singleton lock released: %s", u.singleton)
+	}, true
+}
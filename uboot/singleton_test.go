@@ -0,0 +1,77 @@
+package uboot_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uw/uboot"
+	"uw/uboot/uboottest"
+)
+
+func TestSingleton(t *testing.T) {
+	lock := filepath.Join(t.TempDir(), "scheduler.lock")
+
+	replica := func() (*uboottest.Harness, chan bool) {
+		leader := make(chan bool, 1)
+		h := uboottest.New().Register(
+			uboot.Uint("scheduler", uboot.UintDaemon, func(c *uboot.Context) error {
+				leader <- c.Leader()
+				<-c.Context().Done()
+				return nil
+			}).Singleton(lock),
+		)
+
+		return h, leader
+	}
+
+	h1, leader1 := replica()
+	done1 := h1.StartAsync()
+
+	select {
+	case ok := <-leader1:
+		if !ok {
+			t.Fatal("first replica not leader")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("first replica not started")
+	}
+
+	h2, leader2 := replica()
+	done2 := h2.StartAsync()
+
+	if !h2.Clock.WaitTimers(1, time.Second) {
+		t.Fatal("second replica not waiting")
+	}
+
+	if s := h2.Boot.Status()[0]; s.State != uboot.UintStandby || s.Leader {
+		t.Fatalf("second replica status: %+v", s)
+	}
+
+	if s := h1.Boot.Status()[0]; !s.Leader {
+		t.Fatalf("first replica status: %+v", s)
+	}
+
+	h1.Shutdown()
+	if e := <-done1; e != nil {
+		t.Fatal(e)
+	}
+
+	h2.Clock.Advance(time.Second)
+
+	select {
+	case ok := <-leader2:
+		if !ok {
+			t.Fatal("second replica not leader")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second replica not took over")
+	}
+
+	h2.Shutdown()
+	if e := <-done2; e != nil {
+		t.Fatal(e)
+	}
+
+	h2.AssertOrder(t, "scheduler")
+}
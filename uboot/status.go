@@ -0,0 +1,194 @@
+package uboot
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"uw/uweb"
+)
+
+type UintState uint8
+
+const (
+	UintPending    UintState = iota // 等待启动
+	UintStarting                    // 启动中 (等待依赖)
+	UintRunning                     // 运行中
+	UintStopping                    // 停止中 (运行停止钩子)
+	UintStopped                     // 已停止
+	UintFailed                      // 已失败
+	UintRestarting                  // 等待重启
+	UintStandby                     // 备用实例, 等待单例锁
+)
+
+func UintStateString(state UintState) string {
+	switch state {
+	case UintPending:
+		return "pending"
+	case UintStarting:
+		return "starting"
+	case UintRunning:
+		return "running"
+	case UintStopping:
+		return "stopping"
+	case UintStopped:
+		return "stopped"
+	case UintFailed:
+		return "failed"
+	case UintRestarting:
+		return "restarting"
+	case UintStandby:
+		return "standby"
+	default:
+		return "unknown"
+	}
+}
+
+func (s UintState) String() string {
+	return UintStateString(s)
+}
+
+func (s UintState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// 模块状态快照
+type UintStatus struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	State     UintState `json:"state"`
+	StartedAt time.Time `json:"started_at"`
+	StoppedAt time.Time `json:"stopped_at"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"last_error,omitempty"`
+	Cron      *CronRun  `json:"cron,omitempty"`   // 定时模块运行记录
+	Leader    bool      `json:"leader,omitempty"` // 跨进程单例模块是否持有锁
+}
+
+// 模块运行状态
+type uintStatus struct {
+	lock      sync.RWMutex
+	state     UintState
+	startedAt time.Time
+	stoppedAt time.Time
+	restarts  int
+	lastError error
+	now       func() time.Time // Boot 的时钟
+}
+
+func (s *uintStatus) set(state UintState, e error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch state {
+	case UintStarting:
+		s.startedAt, s.stoppedAt = s.clock(), time.Time{}
+	case UintStopped, UintFailed:
+		s.stoppedAt = s.clock()
+	case UintRestarting:
+		s.restarts++
+	}
+
+	if e != nil {
+		s.lastError = e
+	}
+
+	s.state = state
+}
+
+func (s *uintStatus) clock() time.Time {
+	if s.now == nil {
+		return time.Now()
+	}
+
+	return s.now()
+}
+
+func (s *uintStatus) get() UintState {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.state
+}
+
+func (u *UintAgent) Status() UintStatus {
+	u.status.lock.RLock()
+	defer u.status.lock.RUnlock()
+
+	us := UintStatus{
+		Name:      u.name,
+		Type:      UintTypeString(u.utype),
+		State:     u.status.state,
+		StartedAt: u.status.startedAt,
+		StoppedAt: u.status.stoppedAt,
+		Restarts:  u.status.restarts,
+		Leader:    u.leader.Load(),
+	}
+
+	if u.status.lastError != nil {
+		us.LastError = u.status.lastError.Error()
+	}
+
+	if u.cron != nil {
+		h := u.cron.history()
+		us.Cron = &h
+	}
+
+	return us
+}
+
+// 所有模块的状态快照, 按启动顺序排列
+func (b *Boot) Status() []UintStatus {
+	list := []UintStatus{}
+	for _, phase := range b.phases() {
+		for _, u := range phase {
+			list = append(list, u.Status())
+		}
+	}
+
+	return list
+}
+
+// 是否存活, 正在关闭或者有守护模块失败时返回 false
+func (b *Boot) Live() bool {
+	if b.stopping() {
+		return false
+	}
+
+	for _, u := range b.phases()[UintDaemon] {
+		if u.status.get() == UintFailed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 是否就绪, 所有默认模块完成后返回 true, 开始关闭后返回 false
+func (b *Boot) Ready() bool {
+	return b.ready.Load() && !b.stopping()
+}
+
+// 存活探针, 用于 Kubernetes livenessProbe
+func (b *Boot) LivenessHandler() uweb.HandlerFunc {
+	return b.probeHandler(b.Live)
+}
+
+// 就绪探针, 用于 Kubernetes readinessProbe
+func (b *Boot) ReadinessHandler() uweb.HandlerFunc {
+	return b.probeHandler(b.Ready)
+}
+
+func (b *Boot) probeHandler(probe func() bool) uweb.HandlerFunc {
+	return func(c *uweb.Context) {
+		code, status := http.StatusOK, "ok"
+		if !probe() {
+			code, status = http.StatusServiceUnavailable, "unavailable"
+		}
+
+		c.JSON(code, map[string]any{
+			"status": status,
+			"uints":  b.Status(),
+		})
+	}
+}
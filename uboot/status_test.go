@@ -0,0 +1,46 @@
+package uboot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBootStatus(t *testing.T) {
+	b := NewBoot().Signals().Register(
+		Uint("ok", UintNormal, func(c *Context) error { return nil }),
+		Uint("bad", UintNormal, func(c *Context) error { return errors.New("bad") }).Recover(),
+		Uint("daemon", UintDaemon, func(c *Context) error {
+			<-c.Context().Done()
+			return nil
+		}),
+	)
+
+	if b.Ready() {
+		t.Fatal("ready before start")
+	}
+
+	go b.Start()
+	time.Sleep(50 * time.Millisecond)
+
+	if !b.Ready() || !b.Live() {
+		t.Fatal("not ready after normal uint done")
+	}
+
+	want := map[string]UintState{"ok": UintStopped, "bad": UintFailed, "daemon": UintRunning}
+	for _, s := range b.Status() {
+		if s.State != want[s.Name] {
+			t.Fatalf("%s state: %s", s.Name, s.State)
+		}
+	}
+
+	if s := b.Status()[1]; s.LastError != "bad" || s.StoppedAt.IsZero() {
+		t.Fatalf("bad status: %+v", s)
+	}
+
+	b.Shutdown(context.Background())
+	if b.Ready() || b.Live() {
+		t.Fatal("ready after shutdown")
+	}
+}
@@ -2,13 +2,18 @@ package uboot
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"sync"
 
 	"uw/umap"
 )
 
 var (
-	storage       = umap.NewHmap[string, any]()
-	storageSignal = make(chan struct{})
+	storage        = umap.NewHmap[string, any]()
+	storageLock    = &sync.Mutex{}                // 写入锁, 保证比较交换/更新的原子性
+	storageChanged = make(chan struct{})          // 每次写入时关闭并替换, 用于唤醒所有 LoadWait
+	storageWatcher = map[string][]*storageWatch{} // 键 => 监听者, 受 storageLock 保护
 )
 
 type Key struct {
@@ -25,26 +30,77 @@ func (k Key) String() string {
 	return k.string
 }
 
-func storageNotify(ctx context.Context) error {
-	for {
-		select {
-		case <-storageSignal:
-			continue
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			return nil
+// 带类型的键, 读写的类型在编译时检查
+type TypedKey[T any] struct {
+	Key
+}
+
+func NewTypedKey[T any](value string) TypedKey[T] {
+	return TypedKey[T]{
+		Key: NewKey(value),
+	}
+}
+
+const maxWatchPending = 1024 // 每个监听者最多保存的未送达的值, 超过后丢弃最旧的值
+
+// 存储监听者, 保存未送达的值, 接收者跟得上时每次更新都能送达
+type storageWatch struct {
+	lock    sync.Mutex
+	pending []any
+	signal  chan struct{}
+}
+
+func (w *storageWatch) push(value any) {
+	w.lock.Lock()
+	if len(w.pending) >= maxWatchPending {
+		w.pending = w.pending[1:]
+	}
+	w.pending = append(w.pending, value)
+	w.lock.Unlock()
+
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (w *storageWatch) take() []any {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	p := w.pending
+	w.pending = nil
+	return p
+}
+
+// 通知存储变化, 需要持有 storageLock
+func storageNotify(key string, value any, set bool) {
+	close(storageChanged)
+	storageChanged = make(chan struct{})
+
+	if set {
+		for _, w := range storageWatcher[key] {
+			w.push(value)
 		}
 	}
 }
 
+func storageSet(key string, value any) {
+	storageLock.Lock()
+	defer storageLock.Unlock()
+
+	storage.Set(key, value)
+	storageNotify(key, value, true)
+}
+
 func Set(key Key, value any) {
-	storage.Set(key.String(), value)
+	storageSet(key.String(), value)
 }
 
+// 设置值, 与 Set 相同, 所有 LoadWait 都会被唤醒
 func SetWait(ctx context.Context, key Key, value any) error {
-	storage.Set(key.String(), value)
-	return storageNotify(ctx)
+	storageSet(key.String(), value)
+	return nil
 }
 
 func Load[T any](key Key) (T, bool) {
@@ -67,20 +123,25 @@ func Get[T any](key Key, defaultValue ...T) T {
 	return v
 }
 
+// 等待值被设置
+// @param ctx 上下文, 取消时返回错误
+// @param key 键
+// @return 值 (类型必须匹配)
 func LoadWait[T any](ctx context.Context, key Key) (T, error) {
-	if v, ok := Load[T](key); ok {
-		return v, nil
-	}
-
 	for {
+		storageLock.Lock()
+		changed := storageChanged
+		storageLock.Unlock()
+
+		if v, ok := Load[T](key); ok {
+			return v, nil
+		}
+
 		select {
 		case <-ctx.Done():
 			var empty T
 			return empty, ctx.Err()
-		case storageSignal <- struct{}{}:
-			if v, ok := Load[T](key); ok {
-				return v, nil
-			}
+		case <-changed:
 		}
 	}
 }
@@ -96,6 +157,151 @@ func Range[T any](f func(key Key, value T) bool) {
 	})
 }
 
+// 使用字符串设置值, 已有值时转换为已有值的类型
+// @description 支持基本类型, time.Duration, time.Time 和 []string (逗号分隔), 用于命令行/控制台
+func SetString(key Key, s string) error {
+	storageLock.Lock()
+	defer storageLock.Unlock()
+
+	var value any = s
+	if old, ok := storage.Load(key.String()); ok && old != nil {
+		v := reflect.New(reflect.TypeOf(old)).Elem()
+		if e := setFieldString(v, s); e != nil {
+			return fmt.Errorf("set %s: %w", key, e)
+		}
+
+		value = v.Interface()
+	}
+
+	storage.Set(key.String(), value)
+	storageNotify(key.String(), value, true)
+	return nil
+}
+
 func Remove(key Key) {
+	storageLock.Lock()
+	defer storageLock.Unlock()
+
 	storage.Delete(key.String())
+	storageNotify(key.String(), nil, false)
+}
+
+func (k TypedKey[T]) Set(value T) {
+	storageSet(k.String(), value)
+}
+
+func (k TypedKey[T]) Load() (T, bool) {
+	return Load[T](k.Key)
+}
+
+func (k TypedKey[T]) Get(defaultValue ...T) T {
+	return Get[T](k.Key, defaultValue...)
+}
+
+func (k TypedKey[T]) LoadWait(ctx context.Context) (T, error) {
+	return LoadWait[T](ctx, k.Key)
+}
+
+func (k TypedKey[T]) Remove() {
+	Remove(k.Key)
+}
+
+// 原子更新
+// @description fn 在存储写入锁内运行, 不能再访问 uboot 存储
+// @param fn 更新函数, 参数为当前值以及是否存在
+// @return 更新后的值
+func (k TypedKey[T]) Update(fn func(old T, ok bool) T) T {
+	storageLock.Lock()
+	defer storageLock.Unlock()
+
+	old, ok := Load[T](k.Key)
+	value := fn(old, ok)
+
+	storage.Set(k.String(), value)
+	storageNotify(k.String(), value, true)
+	return value
+}
+
+// 比较并交换, 当前值等于 old 时设置为 new, 不存在的值视为零值, 类型不匹配的值不会被覆盖
+func CompareAndSwap[T comparable](key TypedKey[T], old, new T) bool {
+	storageLock.Lock()
+	defer storageLock.Unlock()
+
+	var cur T
+	if v, ok := storage.Load(key.String()); ok {
+		if cur, ok = v.(T); !ok {
+			return false
+		}
+	}
+
+	if cur != old {
+		return false
+	}
+
+	storage.Set(key.String(), new)
+	storageNotify(key.String(), new, true)
+	return true
+}
+
+// 监听值的变化
+// @description 如果当前已经有值会先发送当前值, 之后每次设置都会按顺序送达,
+// 未读取的值超过 1024 个时丢弃最旧的值, 类型不匹配的值会被忽略, 上下文取消后 channel 会被关闭
+// @param ctx 上下文
+// @param key 键
+// @return 值 channel
+func Watch[T any](ctx context.Context, key TypedKey[T]) <-chan T {
+	w := &storageWatch{signal: make(chan struct{}, 1)}
+
+	storageLock.Lock()
+	if v, ok := storage.Load(key.String()); ok {
+		w.push(v)
+	}
+	storageWatcher[key.String()] = append(storageWatcher[key.String()], w)
+	storageLock.Unlock()
+
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		defer unwatch(key.String(), w)
+
+		for {
+			for _, v := range w.take() {
+				t, ok := v.(T)
+				if !ok {
+					continue
+				}
+
+				select {
+				case out <- t:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-w.signal:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+func unwatch(key string, w *storageWatch) {
+	storageLock.Lock()
+	defer storageLock.Unlock()
+
+	list := storageWatcher[key]
+	for i := range list {
+		if list[i] == w {
+			storageWatcher[key] = append(list[:i:i], list[i+1:]...)
+			break
+		}
+	}
+
+	if len(storageWatcher[key]) < 1 {
+		delete(storageWatcher, key)
+	}
 }
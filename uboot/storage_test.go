@@ -0,0 +1,97 @@
+package uboot
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTypedKey(t *testing.T) {
+	key := NewTypedKey[int]("test.typed")
+	defer key.Remove()
+
+	if v := key.Get(7); v != 7 {
+		t.Fatalf("default: %d", v)
+	}
+
+	if !CompareAndSwap(key, 0, 1) || CompareAndSwap(key, 0, 2) {
+		t.Fatal("compare and swap")
+	}
+
+	// 类型不匹配的值不能视为零值覆盖
+	other := NewTypedKey[int]("test.typed.other")
+	defer other.Remove()
+	Set(other.Key, "x")
+	if CompareAndSwap(other, 0, 1) || Get[string](other.Key) != "x" {
+		t.Fatal("compare and swap type mismatch")
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key.Update(func(old int, ok bool) int { return old + 1 })
+		}()
+	}
+	wg.Wait()
+
+	if v := key.Get(); v != 101 {
+		t.Fatalf("update: %d", v)
+	}
+}
+
+func TestStorageWatch(t *testing.T) {
+	key := NewTypedKey[string]("test.watch")
+	defer key.Remove()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watchers := []<-chan string{Watch(ctx, key), Watch(ctx, key)}
+
+	waiters := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			v, _ := key.LoadWait(ctx)
+			waiters <- v
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	for _, v := range []string{"a", "b", "c"} {
+		key.Set(v)
+	}
+
+	for _, w := range watchers {
+		for _, want := range []string{"a", "b", "c"} {
+			if v := <-w; v != want {
+				t.Fatalf("watch: %s, want %s", v, want)
+			}
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-waiters:
+		case <-time.After(time.Second):
+			t.Fatal("load wait not woken")
+		}
+	}
+
+	cancel()
+	if _, ok := <-watchers[0]; ok {
+		t.Fatal("watch not closed")
+	}
+
+	// 接收者不读取时只保留最新的值
+	w := &storageWatch{signal: make(chan struct{}, 1)}
+	for i := 0; i < maxWatchPending+10; i++ {
+		w.push(i)
+	}
+
+	if p := w.take(); len(p) != maxWatchPending || p[0] != 10 {
+		t.Fatalf("pending: %d %v", len(p), p[0])
+	}
+}
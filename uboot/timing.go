@@ -0,0 +1,316 @@
+package uboot
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"uw/ulog"
+)
+
+// 模块启动耗时
+type UintTiming struct {
+	Name     string        `json:"name"`
+	Phase    string        `json:"phase"`
+	Queued   time.Time     `json:"queued"`   // 所在运行时机开始的时间
+	Started  time.Time     `json:"started"`  // 开始运行的时间
+	Finished time.Time     `json:"finished"` // 运行结束的时间, 仍在运行时为零值
+	Queue    time.Duration `json:"queue"`    // 排队时间, 等待同一运行时机前面的模块
+	Require  time.Duration `json:"require"`  // 阻塞在依赖和 Require 上的时间
+	Run      time.Duration `json:"run"`      // 运行时间, 包含 Require 时间
+	Outcome  string        `json:"outcome"`  // ok/error/panic/timeout/running
+	Error    string        `json:"error,omitempty"`
+}
+
+// 时间段, 用于启动追踪
+type timingSpan struct {
+	name       string
+	start, end time.Time
+}
+
+// 模块第一次运行的耗时记录, 守护模块重启不会记录
+type uintTiming struct {
+	lock     sync.Mutex
+	queued   time.Time
+	started  time.Time
+	finished time.Time
+	requires []timingSpan
+	outcome  string
+	err      error
+}
+
+// 启动耗时记录
+type bootTiming struct {
+	lock      sync.Mutex
+	started   time.Time
+	finished  time.Time
+	phases    []timingSpan
+	traceFile string
+}
+
+// 启动完成后写入 Chrome trace-event 格式的启动追踪文件, 可以使用 chrome://tracing 或 Perfetto 打开
+func (b *Boot) TraceFile(path string) *Boot {
+	b.timing.traceFile = path
+	return b
+}
+
+// 开始运行时机, 记录运行时机内所有模块的排队时间
+// @return 结束运行时机
+func (b *Boot) beginPhase(utype UintType, list []*UintAgent) func() {
+	now := b.now()
+	for _, u := range list {
+		u.timing.lock.Lock()
+		if u.timing.queued.IsZero() {
+			u.timing.queued = now
+		}
+		u.timing.lock.Unlock()
+	}
+
+	return func() {
+		b.timing.lock.Lock()
+		defer b.timing.lock.Unlock()
+
+		b.timing.phases = append(b.timing.phases, timingSpan{name: UintTypeString(utype), start: now, end: b.now()})
+	}
+}
+
+// 模块开始运行, 只有第一次运行会返回耗时记录
+func (u *UintAgent) beginTiming(now time.Time) *uintTiming {
+	u.timing.lock.Lock()
+	defer u.timing.lock.Unlock()
+
+	if !u.timing.started.IsZero() {
+		return nil
+	}
+
+	u.timing.started = now
+	return &u.timing
+}
+
+func (t *uintTiming) require(name string, start, end time.Time) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.requires = append(t.requires, timingSpan{name: name, start: start, end: end})
+}
+
+func (t *uintTiming) finish(now time.Time, e error, timedOut bool) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var pe *PanicError
+
+	t.finished, t.err, t.outcome = now, e, "ok"
+	switch {
+	case timedOut:
+		t.outcome = "timeout"
+	case errors.As(e, &pe):
+		t.outcome = "panic"
+	case e != nil:
+		t.outcome = "error"
+	}
+}
+
+func (u *UintAgent) timingSnapshot(now time.Time) UintTiming {
+	u.timing.lock.Lock()
+	defer u.timing.lock.Unlock()
+
+	ut := UintTiming{
+		Name:     u.name,
+		Phase:    UintTypeString(u.utype),
+		Queued:   u.timing.queued,
+		Started:  u.timing.started,
+		Finished: u.timing.finished,
+		Outcome:  u.timing.outcome,
+	}
+
+	if u.timing.err != nil {
+		ut.Error = u.timing.err.Error()
+	}
+
+	if ut.Started.IsZero() {
+		ut.Outcome = "pending"
+		return ut
+	}
+
+	end := ut.Finished
+	if end.IsZero() {
+		end, ut.Outcome = now, "running"
+	}
+
+	ut.Queue, ut.Run = ut.Started.Sub(ut.Queued), end.Sub(ut.Started)
+	for _, s := range u.timing.requires {
+		ut.Require += s.end.Sub(s.start)
+	}
+
+	return ut
+}
+
+// 所有模块的启动耗时, 按 排队时间 + 运行时间 从大到小排列, 不包含定时模块
+func (b *Boot) Timings() []UintTiming {
+	now, list := b.now(), []UintTiming{}
+	for _, phase := range b.phases() {
+		for _, u := range phase {
+			if u.utype != UintCron {
+				list = append(list, u.timingSnapshot(now))
+			}
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Queue+list[i].Run > list[j].Queue+list[j].Run
+	})
+
+	return list
+}
+
+func (b *Boot) printTimings() {
+	b.timing.lock.Lock()
+	b.timing.finished = b.now()
+	total := b.timing.finished.Sub(b.timing.started)
+	b.timing.lock.Unlock()
+
+	list := b.Timings()
+	if len(list) < 1 {
+		return
+	}
+
+	width := len("uint")
+	for _, t := range list {
+		if len(t.Name) > width {
+			width = len(t.Name)
+		}
+	}
+
+	b.printf(ulog.SetANSI(ulog.ANSI.Bold, "uboot startup report (%s)"), total)
+	b.printf("%-*s  %-10s  %10s  %10s  %10s  %s", width, "uint", "phase", "queue", "require", "run", "outcome")
+	for _, t := range list {
+		b.printf("%-*s  %-10s  %10s  %10s  %10s  %s", width, t.Name, t.Phase,
+			formatDuration(t.Queue), formatDuration(t.Require), formatDuration(t.Run), t.Outcome)
+	}
+
+	if b.timing.traceFile != "" {
+		if e := b.writeTraceFile(b.timing.traceFile); e != nil {
+			b.printf(ulog.SetANSI(ulog.ANSI.Magenta, "write boot trace error: %s"), e)
+			return
+		}
+
+		b.printf("boot trace: %s", b.timing.traceFile)
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
+
+func (b *Boot) writeTraceFile(path string) error {
+	f, e := os.Create(path)
+	if e != nil {
+		return e
+	}
+
+	if e := b.WriteTrace(f); e != nil {
+		f.Close()
+		return e
+	}
+
+	return f.Close()
+}
+
+// Chrome trace-event 格式的事件
+type traceEvent struct {
+	Name string         `json:"name"`
+	Cat  string         `json:"cat,omitempty"`
+	Ph   string         `json:"ph"`
+	Ts   int64          `json:"ts"`
+	Dur  int64          `json:"dur,omitempty"`
+	Pid  int            `json:"pid"`
+	Tid  int            `json:"tid"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+// 写入 Chrome trace-event 格式的启动追踪
+// @description 每个模块一个线程, 包含 queue/require/run 三种事件, 线程 0 为运行时机
+func (b *Boot) WriteTrace(w io.Writer) error {
+	b.timing.lock.Lock()
+	origin, phases := b.timing.started, append([]timingSpan{}, b.timing.phases...)
+	b.timing.lock.Unlock()
+
+	now := b.now()
+	ts := func(t time.Time) int64 {
+		return t.Sub(origin).Microseconds()
+	}
+
+	span := func(name, cat string, tid int, start, end time.Time, args map[string]any) traceEvent {
+		if end.IsZero() {
+			end = now
+		}
+
+		return traceEvent{Name: name, Cat: cat, Ph: "X", Ts: ts(start), Dur: end.Sub(start).Microseconds(), Tid: tid, Args: args}
+	}
+
+	events := []traceEvent{
+		{Name: "thread_name", Ph: "M", Args: map[string]any{"name": "uboot"}},
+	}
+
+	for _, p := range phases {
+		events = append(events, span(p.name, "phase", 0, p.start, p.end, nil))
+	}
+
+	tid := 0
+	for _, phase := range b.phases() {
+		for _, u := range phase {
+			if u.utype == UintCron {
+				continue
+			}
+
+			tid++
+			ut := u.timingSnapshot(now)
+			name := UintTypeString(u.utype) + ":" + u.name
+			events = append(events, traceEvent{Name: "thread_name", Ph: "M", Tid: tid, Args: map[string]any{"name": name}})
+
+			if ut.Queued.IsZero() {
+				continue
+			}
+
+			if ut.Started.IsZero() {
+				events = append(events, span("queue", "queue", tid, ut.Queued, now, nil))
+				continue
+			}
+
+			events = append(events, span("queue", "queue", tid, ut.Queued, ut.Started, nil))
+
+			args := map[string]any{"outcome": ut.Outcome}
+			if ut.Error != "" {
+				args["error"] = ut.Error
+			}
+			events = append(events, span(u.name, "run", tid, ut.Started, ut.Finished, args))
+
+			u.timing.lock.Lock()
+			for _, s := range u.timing.requires {
+				events = append(events, span("require "+s.name, "require", tid, s.start, s.end, nil))
+			}
+			u.timing.lock.Unlock()
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(map[string]any{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	})
+}
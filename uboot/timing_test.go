@@ -0,0 +1,96 @@
+package uboot_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uw/uboot"
+	"uw/uboot/uboottest"
+)
+
+func TestBootTimings(t *testing.T) {
+	h := uboottest.New()
+	trace := filepath.Join(t.TempDir(), "trace.json")
+	h.Boot.TraceFile(trace)
+
+	h.Register(
+		uboot.Uint("a", uboot.UintFront, func(c *uboot.Context) error {
+			h.Clock.Advance(2 * time.Second)
+			return nil
+		}),
+		uboot.Uint("b", uboot.UintFront, func(c *uboot.Context) error {
+			h.Clock.Advance(3 * time.Second)
+			return nil
+		}).DependsOn("a"),
+		uboot.Uint("bg", uboot.UintBackground, func(c *uboot.Context) error {
+			for !h.Logged("require: bg") {
+				time.Sleep(time.Millisecond)
+			}
+
+			time.Sleep(10 * time.Millisecond)
+			h.Clock.Advance(time.Second)
+			return nil
+		}),
+		uboot.Uint("n", uboot.UintNormal, func(c *uboot.Context) error {
+			return c.Require(context.Background(), "bg")
+		}),
+	)
+
+	if e := h.Start(); e != nil {
+		t.Fatal(e)
+	}
+
+	timings := map[string]uboot.UintTiming{}
+	for _, ut := range h.Boot.Timings() {
+		timings[ut.Name] = ut
+	}
+
+	if b := timings["b"]; b.Queue != 2*time.Second || b.Run != 3*time.Second || b.Outcome != "ok" {
+		t.Fatalf("b timing: %+v", b)
+	}
+
+	if n := timings["n"]; n.Require != time.Second || n.Run != time.Second {
+		t.Fatalf("n timing: %+v", n)
+	}
+
+	if list := h.Boot.Timings(); list[0].Name != "b" {
+		t.Fatalf("timings not sorted: %+v", list)
+	}
+
+	if !h.Logged("uboot startup report (6s)") {
+		t.Fatalf("report not printed: %v", h.Logs())
+	}
+
+	data, e := os.ReadFile(trace)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	v := struct {
+		TraceEvents []struct {
+			Name string `json:"name"`
+			Cat  string `json:"cat"`
+			Ts   int64  `json:"ts"`
+			Dur  int64  `json:"dur"`
+		} `json:"traceEvents"`
+	}{}
+	if e := json.NewDecoder(bytes.NewReader(data)).Decode(&v); e != nil {
+		t.Fatal(e)
+	}
+
+	found := false
+	for _, ev := range v.TraceEvents {
+		if ev.Cat == "require" && ev.Name == "require bg" && ev.Dur == time.Second.Microseconds() {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Fatalf("require event not found: %s", data)
+	}
+}
@@ -0,0 +1,168 @@
+package uboottest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"uw/uboot"
+)
+
+// 假时钟, 只有调用 Advance 时时间才会前进
+type FakeClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	changed chan struct{} // 定时器数量变化时关闭并替换
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now:     now,
+		changed: make(chan struct{}),
+	}
+}
+
+func (fc *FakeClock) Now() time.Time {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	return fc.now
+}
+
+func (fc *FakeClock) NewTimer(d time.Duration) uboot.Timer {
+	t := &fakeTimer{fc: fc, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+func (fc *FakeClock) AfterFunc(d time.Duration, f func()) uboot.Timer {
+	t := &fakeTimer{fc: fc, f: f}
+	t.Reset(d)
+	return t
+}
+
+// 时间前进 d, 按到期顺序触发到期的定时器
+// @description AfterFunc 的函数在当前协程中同步运行
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.lock.Lock()
+	target := fc.now.Add(d)
+	fc.lock.Unlock()
+
+	for {
+		fc.lock.Lock()
+		if len(fc.timers) < 1 || fc.timers[0].when.After(target) {
+			fc.now = target
+			fc.lock.Unlock()
+			return
+		}
+
+		t := fc.timers[0]
+		fc.timers = fc.timers[1:]
+		if t.when.After(fc.now) {
+			fc.now = t.when
+		}
+		now := fc.now
+		fc.notify()
+		fc.lock.Unlock()
+
+		t.fire(now)
+	}
+}
+
+// 等待的定时器数量
+func (fc *FakeClock) Timers() int {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	return len(fc.timers)
+}
+
+// 等待至少 n 个定时器, 用于确认模块已经进入等待
+// @param n 定时器数量
+// @param timeout 真实时间的超时时间
+// @return 是否等到
+func (fc *FakeClock) WaitTimers(n int, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		fc.lock.Lock()
+		count, changed := len(fc.timers), fc.changed
+		fc.lock.Unlock()
+
+		if count >= n {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return false
+		}
+	}
+}
+
+// 需要持有锁
+func (fc *FakeClock) notify() {
+	close(fc.changed)
+	fc.changed = make(chan struct{})
+}
+
+// 需要持有锁
+func (fc *FakeClock) remove(t *fakeTimer) bool {
+	for i := range fc.timers {
+		if fc.timers[i] == t {
+			fc.timers = append(fc.timers[:i], fc.timers[i+1:]...)
+			fc.notify()
+			return true
+		}
+	}
+
+	return false
+}
+
+type fakeTimer struct {
+	fc   *FakeClock
+	when time.Time
+	c    chan time.Time
+	f    func()
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.fc.lock.Lock()
+	defer t.fc.lock.Unlock()
+
+	return t.fc.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.fc.lock.Lock()
+	defer t.fc.lock.Unlock()
+
+	active := t.fc.remove(t)
+	t.when = t.fc.now.Add(d)
+	t.fc.timers = append(t.fc.timers, t)
+	sort.SliceStable(t.fc.timers, func(i, j int) bool {
+		return t.fc.timers[i].when.Before(t.fc.timers[j].when)
+	})
+	t.fc.notify()
+
+	return active
+}
+
+func (t *fakeTimer) fire(now time.Time) {
+	if t.f != nil {
+		t.f()
+		return
+	}
+
+	select {
+	case t.c <- now:
+	default:
+	}
+}
@@ -0,0 +1,260 @@
+// uboot 测试工具, 使用假时钟和捕获的日志在测试中运行 Boot,
+// 记录模块的启动顺序和结果, 并且可以向指定模块注入错误/panic/阻塞
+package uboottest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"uw/uboot"
+	"uw/ulog"
+)
+
+type Outcome uint8
+
+const (
+	NotStarted Outcome = iota // 未运行
+	Running                   // 运行中
+	Completed                 // 成功完成
+	Failed                    // 返回错误或 panic
+	TimedOut                  // 超时
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case NotStarted:
+		return "not started"
+	case Running:
+		return "running"
+	case Completed:
+		return "completed"
+	case Failed:
+		return "failed"
+	case TimedOut:
+		return "timed out"
+	default:
+		return "unknown"
+	}
+}
+
+// 假时钟的默认起始时间
+var Epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var ansiRegexp = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+type Harness struct {
+	Boot  *uboot.Boot
+	Clock *FakeClock
+
+	lock     sync.Mutex
+	logs     []string
+	order    []string
+	runs     map[string]int
+	outcomes map[string]Outcome
+	faults   map[string]uboot.UintHandler
+}
+
+// 创建测试用的 Boot
+// @description 使用假时钟, 捕获日志, 丢弃 logo, 不监听关闭信号
+func New() *Harness {
+	h := &Harness{
+		Clock:    NewFakeClock(Epoch),
+		runs:     map[string]int{},
+		outcomes: map[string]Outcome{},
+		faults:   map[string]uboot.UintHandler{},
+	}
+
+	h.Boot = uboot.NewBoot().
+		SetClock(h.Clock).
+		SetOutput(io.Discard).
+		SetPrintf(h.printf).
+		SetLogger(h.logger()).
+		Signals()
+
+	return h
+}
+
+func (h *Harness) printf(format string, args ...interface{}) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.logs = append(h.logs, ansiRegexp.ReplaceAllString(fmt.Sprintf(format, args...), ""))
+}
+
+// 捕获模块上下文中的日志, 例如 Context.Printf
+func (h *Harness) logger() *ulog.Logger {
+	df := ulog.NewDefaultFormat(func(s string) {
+		h.printf("%s", strings.TrimRight(s, "\r\n"))
+	})
+	df.SetLevel(ulog.DefaultLevelNoColor)
+
+	return ulog.NewLogger(df)
+}
+
+// 注册模块, 只有通过 Harness 注册的模块会记录启动顺序和结果
+func (h *Harness) Register(uints ...*uboot.UintAgent) *Harness {
+	for _, u := range uints {
+		h.Boot.Register(u.Wrap(h.wrap(u.Name())))
+	}
+
+	return h
+}
+
+func (h *Harness) wrap(name string) func(next uboot.UintHandler) uboot.UintHandler {
+	return func(next uboot.UintHandler) uboot.UintHandler {
+		return func(c *uboot.Context) (e error) {
+			h.lock.Lock()
+			h.order = append(h.order, name)
+			h.runs[name]++
+			h.outcomes[name] = Running
+			fault := h.faults[name]
+			h.lock.Unlock()
+
+			defer func() {
+				r := recover()
+
+				o := Completed
+				switch {
+				case c.TimedOut():
+					o = TimedOut
+				case r != nil || e != nil:
+					o = Failed
+				}
+
+				h.lock.Lock()
+				h.outcomes[name] = o
+				h.lock.Unlock()
+
+				if r != nil {
+					panic(r)
+				}
+			}()
+
+			if fault != nil {
+				return fault(c)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// 注入故障, 模块运行时使用 fault 代替处理函数
+func (h *Harness) Inject(name string, fault uboot.UintHandler) *Harness {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.faults[name] = fault
+	return h
+}
+
+// 模块运行时返回错误
+func (h *Harness) Fail(name string, e error) *Harness {
+	return h.Inject(name, func(c *uboot.Context) error {
+		return e
+	})
+}
+
+// 模块运行时 panic
+func (h *Harness) Panic(name string, v any) *Harness {
+	return h.Inject(name, func(c *uboot.Context) error {
+		panic(v)
+	})
+}
+
+// 模块运行时阻塞直到上下文取消, 用于测试超时
+func (h *Harness) Hang(name string) *Harness {
+	return h.Inject(name, func(c *uboot.Context) error {
+		<-c.Context().Done()
+		return c.Context().Err()
+	})
+}
+
+// 同步启动, 模块失败/超时时返回错误
+func (h *Harness) Start() error {
+	return h.Boot.StartE()
+}
+
+// 异步启动, 用于需要推进假时钟的测试
+// @return 启动完成后返回结果
+func (h *Harness) StartAsync() <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- h.Boot.StartE()
+	}()
+
+	return done
+}
+
+// 关闭 Boot
+func (h *Harness) Shutdown() error {
+	return h.Boot.Shutdown(context.Background())
+}
+
+// 处理函数的运行顺序, 守护模块每次重启都会记录
+func (h *Harness) Order() []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return append([]string{}, h.order...)
+}
+
+// 处理函数的运行次数
+func (h *Harness) Runs(name string) int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.runs[name]
+}
+
+// 模块最后一次运行的结果
+func (h *Harness) Outcome(name string) Outcome {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.outcomes[name]
+}
+
+// 捕获的日志, 已去除 ANSI 颜色
+func (h *Harness) Logs() []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return append([]string{}, h.logs...)
+}
+
+// 是否有包含 s 的日志
+func (h *Harness) Logged(s string) bool {
+	for _, l := range h.Logs() {
+		if strings.Contains(l, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 断言处理函数的运行顺序
+func (h *Harness) AssertOrder(t testing.TB, names ...string) {
+	t.Helper()
+
+	if order := h.Order(); !slices.Equal(order, names) {
+		t.Fatalf("uint order: %v, want %v", order, names)
+	}
+}
+
+// 断言模块最后一次运行的结果
+func (h *Harness) AssertOutcome(t testing.TB, name string, want Outcome) {
+	t.Helper()
+
+	if o := h.Outcome(name); o != want {
+		t.Fatalf("uint %s outcome: %s, want %s", name, o, want)
+	}
+}
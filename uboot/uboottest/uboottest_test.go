@@ -0,0 +1,153 @@
+package uboottest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"uw/uboot"
+)
+
+func ok(c *uboot.Context) error {
+	return nil
+}
+
+func TestHarness(t *testing.T) {
+	t.Run("Order", func(t *testing.T) {
+		h := New().Register(
+			uboot.Uint("after", uboot.UintAfter, ok),
+			uboot.Uint("b", uboot.UintFront, ok).DependsOn("a"),
+			uboot.Uint("a", uboot.UintFront, ok),
+		)
+
+		if e := h.Start(); e != nil {
+			t.Fatal(e)
+		}
+
+		h.AssertOrder(t, "a", "b", "after")
+		h.AssertOutcome(t, "b", Completed)
+
+		if !h.Logged("uboot done") {
+			t.Fatalf("logs: %v", h.Logs())
+		}
+	})
+
+	t.Run("Fail", func(t *testing.T) {
+		boom := errors.New("boom")
+		h := New().Register(
+			uboot.Uint("a", uboot.UintFront, ok),
+			uboot.Uint("b", uboot.UintFront, ok).DependsOn("a"),
+			uboot.Uint("c", uboot.UintFront, ok).DependsOn("b"),
+		).Fail("b", boom)
+
+		if e := h.Start(); !errors.Is(e, boom) {
+			t.Fatalf("start error: %v", e)
+		}
+
+		h.AssertOrder(t, "a", "b")
+		h.AssertOutcome(t, "b", Failed)
+		h.AssertOutcome(t, "c", NotStarted)
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		h := New().Register(
+			uboot.Uint("a", uboot.UintNormal, ok),
+		).Panic("a", "boom")
+
+		var pe *uboot.PanicError
+		if e := h.Start(); !errors.As(e, &pe) || pe.Value != "boom" {
+			t.Fatalf("start error: %v", e)
+		}
+
+		h.AssertOutcome(t, "a", Failed)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		h := New().Register(
+			uboot.Uint("slow", uboot.UintNormal, ok).Timeout(time.Minute),
+		).Hang("slow")
+
+		done := h.StartAsync()
+		if !h.Clock.WaitTimers(1, time.Second) {
+			t.Fatal("timeout timer not created")
+		}
+
+		h.Clock.Advance(time.Minute)
+
+		select {
+		case e := <-done:
+			if !errors.Is(e, uboot.ErrUintTimeout) {
+				t.Fatalf("start error: %v", e)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("start not returned")
+		}
+
+		h.AssertOutcome(t, "slow", TimedOut)
+	})
+
+	t.Run("Restart", func(t *testing.T) {
+		h := New().Register(
+			uboot.Uint("daemon", uboot.UintDaemon, ok).Restart(uboot.RestartPolicy{
+				Mode:     uboot.RestartAlways,
+				Interval: 10 * time.Second,
+			}),
+		)
+
+		done := h.StartAsync()
+		for i := 1; i <= 3; i++ {
+			if !h.Clock.WaitTimers(1, time.Second) {
+				t.Fatalf("restart %d timer not created", i)
+			}
+
+			if n := h.Runs("daemon"); n != i {
+				t.Fatalf("runs before restart %d: %d", i, n)
+			}
+
+			h.Clock.Advance(10 * time.Second)
+		}
+
+		if e := h.Shutdown(); e != nil {
+			t.Fatal(e)
+		}
+
+		if e := <-done; e != nil {
+			t.Fatal(e)
+		}
+
+		if got := h.Clock.Now().Sub(Epoch); got != 30*time.Second {
+			t.Fatalf("fake clock: %s", got)
+		}
+	})
+}
+
+func TestFakeClock(t *testing.T) {
+	fc := NewFakeClock(Epoch)
+
+	fired := []int{}
+	fc.AfterFunc(2*time.Second, func() { fired = append(fired, 2) })
+	fc.AfterFunc(time.Second, func() { fired = append(fired, 1) })
+	stopped := fc.AfterFunc(time.Second, func() { fired = append(fired, 0) })
+
+	if !stopped.Stop() || stopped.Stop() {
+		t.Fatal("stop result")
+	}
+
+	tm := fc.NewTimer(3 * time.Second)
+	fc.Advance(2 * time.Second)
+
+	if len(fired) != 2 || fired[0] != 1 || fired[1] != 2 {
+		t.Fatalf("fired: %v", fired)
+	}
+
+	select {
+	case <-tm.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	fc.Advance(time.Second)
+	if now := <-tm.C(); !now.Equal(Epoch.Add(3 * time.Second)) {
+		t.Fatalf("timer time: %s", now)
+	}
+}
@@ -0,0 +1,295 @@
+// uboot 管理控制台, 通过 SSH 连接到运行中的服务,
+// 查看模块状态, 重启守护模块, 读写存储, 发布事件, 修改日志等级和查看实时日志
+package uconsole
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"sync"
+
+	"uw/pkg/x/crypto/ssh"
+	"uw/uboot"
+	"uw/ulog"
+)
+
+const (
+	defaultAddr    = "127.0.0.1:2222" // 默认监听地址
+	tailHistory    = 100              // tail 输出的历史日志数量
+	tailBufferSize = 256              // 每个 tail 会话的缓冲区, 满时丢弃
+)
+
+type Options struct {
+	Name               string              // 模块名称, 默认 console
+	Addr               string              // 监听地址, 默认 127.0.0.1:2222
+	HostKey            ssh.Signer          // 主机密钥, 为空时使用 HostKeyFile
+	HostKeyFile        string              // 主机密钥文件 (PEM), 都为空时生成临时密钥
+	AuthorizedKeys     []ssh.PublicKey     // 允许登录的公钥
+	AuthorizedKeysFile string              // authorized_keys 格式的公钥文件
+	Logger             *ulog.Logger        // tail 的日志来源, 默认 ulog.GlobalLogger()
+	Format             *ulog.DefaultFormat // level 修改的格式化接口, 默认 ulog.GlobalFormat()
+}
+
+type Console struct {
+	opts   Options
+	config *ssh.ServerConfig
+	keys   [][]byte // 允许登录的公钥
+
+	lock    sync.Mutex
+	addr    net.Addr
+	history []ulog.Log             // 最近的日志
+	tails   map[chan ulog.Log]bool // tail 会话
+	once    sync.Once              // 只注册一次日志格式化接口
+	printf  uboot.Printf
+}
+
+// 创建控制台
+// @description 没有允许登录的公钥时返回错误
+func New(opts Options) (*Console, error) {
+	if opts.Name == "" {
+		opts.Name = "console"
+	}
+
+	if opts.Addr == "" {
+		opts.Addr = defaultAddr
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = ulog.GlobalLogger()
+	}
+
+	if opts.Format == nil {
+		opts.Format = ulog.GlobalFormat()
+	}
+
+	cs := &Console{
+		opts:   opts,
+		tails:  map[chan ulog.Log]bool{},
+		printf: ulog.Printf,
+	}
+
+	for _, k := range opts.AuthorizedKeys {
+		cs.keys = append(cs.keys, k.Marshal())
+	}
+
+	if opts.AuthorizedKeysFile != "" {
+		data, e := os.ReadFile(opts.AuthorizedKeysFile)
+		if e != nil {
+			return nil, e
+		}
+
+		for len(bytes.TrimSpace(data)) > 0 {
+			k, _, _, rest, e := ssh.ParseAuthorizedKey(data)
+			if e != nil {
+				return nil, e
+			}
+
+			cs.keys, data = append(cs.keys, k.Marshal()), rest
+		}
+	}
+
+	if len(cs.keys) < 1 {
+		return nil, errors.New("console without authorized keys")
+	}
+
+	hostKey, e := loadHostKey(opts)
+	if e != nil {
+		return nil, e
+	}
+
+	cs.config = &ssh.ServerConfig{
+		PublicKeyCallback: cs.authorize,
+	}
+	cs.config.AddHostKey(hostKey)
+
+	return cs, nil
+}
+
+func loadHostKey(opts Options) (ssh.Signer, error) {
+	if opts.HostKey != nil {
+		return opts.HostKey, nil
+	}
+
+	if opts.HostKeyFile != "" {
+		data, e := os.ReadFile(opts.HostKeyFile)
+		if e != nil {
+			return nil, e
+		}
+
+		return ssh.ParsePrivateKey(data)
+	}
+
+	_, key, e := ed25519.GenerateKey(rand.Reader)
+	if e != nil {
+		return nil, e
+	}
+
+	return ssh.NewSignerFromKey(key)
+}
+
+func (cs *Console) authorize(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	data := key.Marshal()
+	for _, k := range cs.keys {
+		if bytes.Equal(k, data) {
+			return &ssh.Permissions{
+				Extensions: map[string]string{"pubkey-fp": ssh.FingerprintSHA256(key)},
+			}, nil
+		}
+	}
+
+	return nil, errors.New("unauthorized public key")
+}
+
+// 控制台守护模块
+func (cs *Console) Uint() *uboot.UintAgent {
+	return uboot.Uint(cs.opts.Name, uboot.UintDaemon, func(c *uboot.Context) error {
+		cs.once.Do(func() {
+			cs.opts.Logger.Register(cs)
+		})
+
+		ln, e := net.Listen("tcp", cs.opts.Addr)
+		if e != nil {
+			return e
+		}
+
+		cs.lock.Lock()
+		cs.addr, cs.printf = ln.Addr(), c.Printf
+		cs.lock.Unlock()
+
+		c.Printf("console listening on %s", ln.Addr())
+		return cs.serve(c.Context(), c.Boot(), ln)
+	})
+}
+
+// 监听地址, 未启动时返回 nil
+func (cs *Console) Addr() net.Addr {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	return cs.addr
+}
+
+func (cs *Console) logf(format string, args ...interface{}) {
+	cs.lock.Lock()
+	printf := cs.printf
+	cs.lock.Unlock()
+
+	printf(format, args...)
+}
+
+func (cs *Console) serve(ctx context.Context, b *uboot.Boot, ln net.Listener) error {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, e := ln.Accept()
+		if e != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return e
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cs.handleConn(ctx, b, conn)
+		}()
+	}
+}
+
+func (cs *Console) handleConn(ctx context.Context, b *uboot.Boot, conn net.Conn) {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+
+		conn.Close()
+	}()
+
+	sc, chans, reqs, e := ssh.NewServerConn(conn, cs.config)
+	if e != nil {
+		cs.logf("console handshake error: %s: %s", conn.RemoteAddr(), e)
+		return
+	}
+	defer sc.Close()
+
+	cs.logf("console login: %s@%s (%s)", sc.User(), sc.RemoteAddr(), sc.Permissions.Extensions["pubkey-fp"])
+	go ssh.DiscardRequests(reqs)
+
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		ch, requests, e := nc.Accept()
+		if e != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			newSession(ctx, cs, b, ch).handle(requests)
+		}()
+	}
+
+	cs.logf("console logout: %s@%s", sc.User(), sc.RemoteAddr())
+}
+
+// 实现 ulog.Format, 记录最近的日志并转发给 tail 会话
+func (cs *Console) Write(log *ulog.Log) {
+	l := *log.Clone()
+
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	if len(cs.history) >= tailHistory {
+		cs.history = append(cs.history[:0], cs.history[1:]...)
+	}
+	cs.history = append(cs.history, l)
+
+	for ch := range cs.tails {
+		select {
+		case ch <- l:
+		default:
+		}
+	}
+}
+
+// 订阅日志
+// @return 日志, 最近的日志, 取消订阅
+func (cs *Console) tail() (chan ulog.Log, []ulog.Log, func()) {
+	ch := make(chan ulog.Log, tailBufferSize)
+
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	cs.tails[ch] = true
+	history := append([]ulog.Log{}, cs.history...)
+
+	return ch, history, func() {
+		cs.lock.Lock()
+		defer cs.lock.Unlock()
+
+		delete(cs.tails, ch)
+	}
+}
@@ -0,0 +1,305 @@
+package uconsole
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"uw/pkg/x/crypto/ssh"
+	"uw/pkg/x/term"
+	"uw/uboot"
+	"uw/uboot/uboottest"
+	"uw/ulog"
+)
+
+func newSigner(t *testing.T) ssh.Signer {
+	_, key, e := ed25519.GenerateKey(rand.Reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	signer, e := ssh.NewSignerFromKey(key)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return signer
+}
+
+func dial(cs *Console, signer ssh.Signer) (*ssh.Client, error) {
+	return ssh.Dial("tcp", cs.Addr().String(), &ssh.ClientConfig{
+		User:            "admin",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         time.Second,
+	})
+}
+
+// 线程安全的输出缓冲
+type buffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *buffer) waitFor(t *testing.T, s string) {
+	t.Helper()
+
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		b.lock.Lock()
+		ok := strings.Contains(b.buf.String(), s)
+		b.lock.Unlock()
+
+		if ok {
+			return
+		}
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	t.Fatalf("output %q not found in:\n%s", s, b.buf.String())
+}
+
+func TestConsole(t *testing.T) {
+	signer := newSigner(t)
+	format := ulog.NewDefaultFormat(func(s string) {})
+
+	cs, e := New(Options{
+		Addr:           "127.0.0.1:0",
+		AuthorizedKeys: []ssh.PublicKey{signer.PublicKey()},
+		Format:         format,
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	runs := &atomic.Int32{}
+	h := uboottest.New().Register(
+		cs.Uint(),
+		uboot.Uint("worker", uboot.UintDaemon, func(c *uboot.Context) error {
+			runs.Add(1)
+			<-c.Context().Done()
+			return nil
+		}),
+	)
+
+	events := make(chan string, 1)
+	if _, e := h.Boot.Events().Subscribe(uboot.NewEventKey("console.test"), func(ctx context.Context, data any) error {
+		events <- data.(string)
+		return nil
+	}); e != nil {
+		t.Fatal(e)
+	}
+
+	key := uboot.NewKey("uconsole.test")
+	uboot.Set(key, 1)
+	defer uboot.Remove(key)
+
+	done := h.StartAsync()
+	defer func() {
+		h.Shutdown()
+		<-done
+	}()
+
+	for deadline := time.Now().Add(2 * time.Second); cs.Addr() == nil; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("console not listening")
+		}
+	}
+
+	if _, e := dial(cs, newSigner(t)); e == nil {
+		t.Fatal("unauthorized key accepted")
+	}
+
+	client, e := dial(cs, signer)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer client.Close()
+
+	exec := func(cmd string) string {
+		t.Helper()
+
+		s, e := client.NewSession()
+		if e != nil {
+			t.Fatal(e)
+		}
+		defer s.Close()
+
+		out, e := s.CombinedOutput(cmd)
+		if e != nil {
+			t.Fatalf("%s: %s: %s", cmd, e, out)
+		}
+
+		return string(out)
+	}
+
+	if out := exec("units"); !strings.Contains(out, "worker") || !strings.Contains(out, "running") {
+		t.Fatalf("units: %s", out)
+	}
+
+	exec("restart worker")
+	for deadline := time.Now().Add(2 * time.Second); runs.Load() < 2; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("worker not restarted")
+		}
+	}
+
+	exec("set uconsole.test 42")
+	if v, ok := uboot.Load[int](key); !ok || v != 42 {
+		t.Fatalf("storage value: %v", v)
+	}
+
+	if out := exec("get"); !strings.Contains(out, "uconsole.test (int) = 42") {
+		t.Fatalf("get: %s", out)
+	}
+
+	exec("publish console.test hello world")
+	if data := <-events; data != "hello world" {
+		t.Fatalf("event data: %s", data)
+	}
+
+	if out := exec("level info error"); out != "level: info error color\n" {
+		t.Fatalf("level: %q", out)
+	}
+
+	if format.GetLevel() != ulog.LevelInfo|ulog.LevelError|ulog.LevelColor {
+		t.Fatalf("format level: %d", format.GetLevel())
+	}
+
+	s, e := client.NewSession()
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer s.Close()
+
+	if e := s.RequestPty("xterm", 24, 80, ssh.TerminalModes{}); e != nil {
+		t.Fatal(e)
+	}
+
+	stdin, e := s.StdinPipe()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	out := &buffer{}
+	s.Stdout = out
+
+	if e := s.Shell(); e != nil {
+		t.Fatal(e)
+	}
+
+	out.waitFor(t, "uboot> ")
+	stdin.Write([]byte("tail\r"))
+	time.Sleep(20 * time.Millisecond)
+
+	ulog.GlobalLogger().Info("console tail test")
+	out.waitFor(t, "console tail test")
+
+	stdin.Write([]byte("q"))
+	stdin.Write([]byte("exit\r"))
+
+	if e := s.Wait(); e != nil {
+		t.Fatal(e)
+	}
+}
+
+// 读取阻塞到关闭的通道
+type blockingChannel struct {
+	ssh.Channel
+	closed chan struct{}
+	exited chan struct{} // 发送 exit-status 时关闭
+	once   sync.Once
+}
+
+func (c *blockingChannel) SendRequest(name string, wantReply bool, payload []byte) (bool, error) {
+	if name == "exit-status" && c.exited != nil {
+		close(c.exited)
+	}
+
+	return true, nil
+}
+
+func (c *blockingChannel) Read(p []byte) (int, error) {
+	<-c.closed
+	return 0, io.EOF
+}
+
+func (c *blockingChannel) Write(p []byte) (int, error) { return len(p), nil }
+
+func (c *blockingChannel) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func TestTailStop(t *testing.T) {
+	ch := &blockingChannel{closed: make(chan struct{})}
+	cs := &Console{tails: map[chan ulog.Log]bool{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newSession(ctx, cs, nil, ch)
+	s.term = term.NewTerminal(ch, "")
+	s.w = s.term
+
+	done := make(chan error, 1)
+	go func() { done <- s.tail(nil) }()
+
+	// 会话结束时停止按键的读取也要结束
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("tail not stopped")
+	}
+
+	select {
+	case <-ch.closed:
+	default:
+		t.Fatal("channel not closed")
+	}
+
+	if len(cs.tails) != 0 {
+		t.Fatalf("tails: %d", len(cs.tails))
+	}
+}
+
+func TestShellWindowChange(t *testing.T) {
+	ch := &blockingChannel{closed: make(chan struct{}), exited: make(chan struct{})}
+	s := newSession(context.Background(), &Console{tails: map[chan ulog.Log]bool{}}, nil, ch)
+
+	size := make([]byte, 8)
+	size[3], size[7] = 120, 40
+
+	// 启动 shell 后立即修改窗口大小, 使用 -race 运行时不能有数据竞争
+	requests := make(chan *ssh.Request, 4)
+	requests <- &ssh.Request{Type: "pty-req"}
+	requests <- &ssh.Request{Type: "shell"}
+	requests <- &ssh.Request{Type: "window-change", Payload: size}
+	requests <- &ssh.Request{Type: "pty-req"}
+	close(requests)
+
+	s.handle(requests)
+
+	select {
+	case <-ch.exited:
+	case <-time.After(2 * time.Second):
+		t.Fatal("shell not exited")
+	}
+
+	if !s.pty || s.term == nil {
+		t.Fatalf("session: pty=%v term=%v", s.pty, s.term)
+	}
+}
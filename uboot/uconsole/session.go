@@ -0,0 +1,353 @@
+package uconsole
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"uw/pkg/x/crypto/ssh"
+	"uw/pkg/x/term"
+	"uw/uboot"
+	"uw/ulog"
+)
+
+var errExit = errors.New("exit")
+
+// 控制台命令
+type command struct {
+	usage   string
+	handler func(s *session, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"help":    {"help                      show commands", (*session).help},
+		"units":   {"units                     list uints and state", (*session).units},
+		"restart": {"restart <uint>            restart a daemon uint", (*session).restart},
+		"get":     {"get [key]                 dump storage keys", (*session).get},
+		"set":     {"set <key> <value>         set a storage key", (*session).set},
+		"publish": {"publish <event> [data]    publish an event with string data", (*session).publish},
+		"level":   {"level [level...]          show or set log levels", (*session).level},
+		"tail":    {"tail                      follow logs, press any key to stop", (*session).tail},
+		"exit":    {"exit                      close the session", (*session).exit},
+	}
+}
+
+type session struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	cs     *Console
+	b      *uboot.Boot
+	ch     ssh.Channel
+	w      io.Writer      // 输出, 终端模式时为 term
+	term   *term.Terminal // 终端, 只有 shell 请求才会创建
+	pty    bool           // 是否请求了伪终端
+}
+
+func newSession(ctx context.Context, cs *Console, b *uboot.Boot, ch ssh.Channel) *session {
+	s := &session{cs: cs, b: b, ch: ch, w: ch}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	return s
+}
+
+func (s *session) handle(requests <-chan *ssh.Request) {
+	defer s.ch.Close()
+	defer s.cancel()
+
+	// pty, term 和 w 只在这里修改, shell 或 exec 启动后不再修改, 避免与它们的协程竞争
+	started := false
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			if started {
+				req.Reply(false, nil)
+				continue
+			}
+
+			s.pty = true
+			req.Reply(true, nil)
+		case "window-change":
+			if s.term != nil && len(req.Payload) >= 8 {
+				s.term.SetSize(int(binary.BigEndian.Uint32(req.Payload)), int(binary.BigEndian.Uint32(req.Payload[4:])))
+			}
+			req.Reply(true, nil)
+		case "shell":
+			if started {
+				req.Reply(false, nil)
+				continue
+			}
+
+			started = true
+			s.term = term.NewTerminal(s.ch, "uboot> ")
+			s.w = s.term
+			req.Reply(true, nil)
+			go s.shell()
+		case "exec":
+			var msg struct{ Command string }
+			if started || ssh.Unmarshal(req.Payload, &msg) != nil {
+				req.Reply(false, nil)
+				continue
+			}
+
+			started = true
+			req.Reply(true, nil)
+			go s.exec(msg.Command)
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+func (s *session) close(status uint32) {
+	s.ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+	s.ch.Close()
+}
+
+func (s *session) exec(line string) {
+	status := uint32(0)
+	if e := s.run(line); e != nil && !errors.Is(e, errExit) {
+		fmt.Fprintf(s.ch.Stderr(), "error: %s\n", e)
+		status = 1
+	}
+
+	s.close(status)
+}
+
+// 交互终端, term 已经在 handle 中创建
+func (s *session) shell() {
+	fmt.Fprintf(s.w, "uboot console, type help for commands\n")
+	for {
+		line, e := s.term.ReadLine()
+		if e != nil {
+			break
+		}
+
+		if e := s.run(line); e != nil {
+			if errors.Is(e, errExit) {
+				break
+			}
+
+			fmt.Fprintf(s.w, "error: %s\n", e)
+		}
+	}
+
+	s.close(0)
+}
+
+func (s *session) run(line string) error {
+	args := strings.Fields(line)
+	if len(args) < 1 {
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command: %s, type help for commands", args[0])
+	}
+
+	if args[0] != "help" && args[0] != "tail" && args[0] != "exit" {
+		s.cs.logf("console command: %s", line)
+	}
+
+	return cmd.handler(s, args[1:])
+}
+
+func (s *session) help(args []string) error {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(s.w, "  %s\n", commands[name].usage)
+	}
+
+	return nil
+}
+
+func (s *session) units(args []string) error {
+	tw := tabwriter.NewWriter(s.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tSTATE\tRESTARTS\tSTARTED\tERROR")
+	for _, st := range s.b.Status() {
+		started := "-"
+		if !st.StartedAt.IsZero() {
+			started = st.StartedAt.Format(time.DateTime)
+		}
+
+		state := st.State.String()
+		if st.Leader {
+			state += " (leader)"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", st.Name, st.Type, state, st.Restarts, started, st.LastError)
+	}
+
+	return tw.Flush()
+}
+
+func (s *session) restart(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: restart <uint>")
+	}
+
+	if e := s.b.Restart(args[0]); e != nil {
+		return e
+	}
+
+	fmt.Fprintf(s.w, "restarting %s\n", args[0])
+	return nil
+}
+
+func (s *session) get(args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: get [key]")
+	}
+
+	if len(args) == 1 {
+		v, ok := uboot.Load[any](uboot.NewKey(args[0]))
+		if !ok {
+			return fmt.Errorf("key not found: %s", args[0])
+		}
+
+		fmt.Fprintf(s.w, "%s (%T) = %+v\n", args[0], v, v)
+		return nil
+	}
+
+	type item struct {
+		key   string
+		value any
+	}
+
+	list := []item{}
+	uboot.Range(func(key uboot.Key, value any) bool {
+		list = append(list, item{key.String(), value})
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].key < list[j].key })
+
+	for _, it := range list {
+		fmt.Fprintf(s.w, "%s (%T) = %+v\n", it.key, it.value, it.value)
+	}
+
+	return nil
+}
+
+func (s *session) set(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: set <key> <value>")
+	}
+
+	key := uboot.NewKey(args[0])
+	if e := uboot.SetString(key, strings.Join(args[1:], " ")); e != nil {
+		return e
+	}
+
+	v, _ := uboot.Load[any](key)
+	fmt.Fprintf(s.w, "%s (%T) = %+v\n", args[0], v, v)
+	return nil
+}
+
+func (s *session) publish(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: publish <event> [data]")
+	}
+
+	if e := s.b.Events().Publish(s.ctx, uboot.NewEventKey(args[0]), strings.Join(args[1:], " ")); e != nil {
+		return e
+	}
+
+	fmt.Fprintf(s.w, "published %s\n", args[0])
+	return nil
+}
+
+func (s *session) level(args []string) error {
+	f := s.cs.opts.Format
+
+	if len(args) > 0 {
+		level := f.GetLevel() & ulog.LevelColor
+		for _, name := range args {
+			l, e := ulog.ParseLevel(name)
+			if e != nil {
+				return e
+			}
+
+			level |= l
+		}
+
+		f.SetLevel(level)
+	}
+
+	fmt.Fprintf(s.w, "level: %s\n", strings.Join(ulog.LevelNames(f.GetLevel()), " "))
+	return nil
+}
+
+func (s *session) tail(args []string) error {
+	logs, history, cancel := s.cs.tail()
+	defer cancel()
+
+	format := ulog.NewDefaultFormat(nil)
+	if !s.pty {
+		format.SetLevel(ulog.DefaultLevelNoColor)
+	}
+
+	write := func(l ulog.Log) error {
+		line := strings.TrimRight(format.Format(&l), "\r\n")
+		if !s.pty {
+			line = ulog.CleanANSI(line)
+		}
+
+		_, e := fmt.Fprintf(s.w, "%s\n", line)
+		return e
+	}
+
+	for _, l := range history {
+		if e := write(l); e != nil {
+			return e
+		}
+	}
+
+	// 终端模式下按任意键停止
+	stop := make(chan struct{})
+	if s.term != nil {
+		go func() {
+			defer close(stop)
+			s.ch.Read(make([]byte, 1))
+		}()
+
+		// 读取无法取消, 因为会话结束或写入失败而停止时关闭通道, 使读取返回
+		defer func() {
+			select {
+			case <-stop:
+			default:
+				s.ch.Close()
+				<-stop
+			}
+		}()
+	}
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return nil
+		case <-stop:
+			return nil
+		case l := <-logs:
+			if e := write(l); e != nil {
+				return e
+			}
+		}
+	}
+}
+
+func (s *session) exit(args []string) error {
+	return errExit
+}
@@ -1,7 +1,9 @@
 package uboot
 
 import (
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,16 +15,37 @@ const (
 	UintNormal                     // 默认运行 (运行时机: 3)
 	UintDaemon                     // 守护运行 (运行时机: 4) (函数退出后, 会再次运行)
 	UintAfter                      // 后续运行 (运行时机: 5)
+	UintCron                       // 定时运行 (运行时机: 6) (按计划重复运行, 需要 Schedule)
+)
+
+var (
+	ErrUintTimeout = errors.New("uint start timeout")         // 模块运行超时
+	ErrBootTimeout = errors.New("normal uint start timeout!") // 启动超时
 )
 
 type UintHandler func(c *Context) error
 
 type UintAgent struct {
-	name    string        // 名称
-	handler UintHandler   // 处理函数
-	utype   UintType      // 运行时机
-	recover bool          // 错误恢复/无视
-	timeout time.Duration // 超时时间
+	name     string        // 名称
+	handler  UintHandler   // 处理函数
+	utype    UintType      // 运行时机
+	recover  bool          // 错误恢复/无视
+	timeout  time.Duration // 超时时间
+	depends  []string      // 依赖模块名称
+	restart  RestartPolicy // 守护模块重启策略
+	cron     *cronState    // 定时模块计划
+	commands []string      // 所属子命令
+
+	singleton string      // 跨进程单例锁文件
+	leader    atomic.Bool // 是否持有单例锁
+
+	current      atomic.Pointer[Context] // 守护模块当前运行的上下文
+	forceRestart atomic.Bool             // 守护模块被要求重启
+
+	onStop      func(c *Context) error // 停止钩子
+	stopTimeout time.Duration          // 停止钩子超时时间
+	status      uintStatus             // 运行状态
+	timing      uintTiming             // 启动耗时
 }
 
 func UintTypeString(utype UintType) string {
@@ -37,6 +60,8 @@ func UintTypeString(utype UintType) string {
 		return "daemon"
 	case UintAfter:
 		return "after"
+	case UintCron:
+		return "cron"
 	default:
 		return "unknown"
 	}
@@ -51,6 +76,20 @@ func Uint(name string, utype UintType, handler UintHandler) *UintAgent {
 	}
 }
 
+func (u *UintAgent) Name() string {
+	return u.name
+}
+
+func (u *UintAgent) Type() UintType {
+	return u.utype
+}
+
+// 包装处理函数, 用于给模块添加通用逻辑, 例如统计/故障注入
+func (u *UintAgent) Wrap(mw func(next UintHandler) UintHandler) *UintAgent {
+	u.handler = mw(u.handler)
+	return u
+}
+
 func (u *UintAgent) Timeout(t time.Duration) *UintAgent {
 	u.timeout = t
 	return u
@@ -61,32 +100,91 @@ func (u *UintAgent) Recover() *UintAgent {
 	return u
 }
 
+// 声明依赖的模块, 在依赖模块全部完成前不会运行处理函数
+// @description 依赖定时模块时只等待它的第一次运行完成, 之后的运行不会再阻塞依赖方
+func (u *UintAgent) DependsOn(names ...string) *UintAgent {
+	u.depends = append(u.depends, names...)
+	return u
+}
+
+// 停止钩子, 在 Boot 关闭时按启动顺序的逆序运行
+func (u *UintAgent) OnStop(f func(c *Context) error) *UintAgent {
+	u.onStop = f
+	return u
+}
+
+// 停止钩子超时时间, 未设置时使用 Boot.StopTimeout
+func (u *UintAgent) StopTimeout(t time.Duration) *UintAgent {
+	u.stopTimeout = t
+	return u
+}
+
 func (u *UintAgent) start(c *Context) {
+	if e := u.run(c); e != nil && !u.recover {
+		c.b.fail(fmt.Errorf("[%s] uint start panic: %w", u.name, e))
+	}
+}
+
+// 运行模块, 处理函数的 panic 会带着调用栈记录到模块日志, 并转换为 *PanicError 返回
+func (u *UintAgent) run(c *Context) (e error) {
 	c.Printf("uint starting")
+	u.status.set(UintStarting, nil)
+	c.timing = u.beginTiming(c.b.now())
 
 	defer func() {
 		if r := recover(); r != nil {
-			if !u.recover {
-				c.Printf("uint start error: %v", r)
-				panic(fmt.Sprintf("[%s] uint start panic: %v", u.name, r))
+			if pe, ok := r.(*PanicError); ok {
+				e = pe
+			} else {
+				e = &PanicError{Value: r}
 			}
 
-			c.Printf("uint start error: %v", r)
+			// 调用栈从 panic 的位置开始
+			c.Logger().LogPanic(e)
+		}
+
+		c.timing.finish(c.b.now(), e, c.TimedOut())
+
+		if e != nil {
+			c.Printf("uint start error: %v", e)
+			u.status.set(UintFailed, e)
+		} else {
+			u.status.set(UintStopped, nil)
 		}
 
 		if cwc := c.b.require.Get(u.name); cwc != nil {
-			cwc.Cancel()
+			if e != nil {
+				cwc.fail(e)
+			} else {
+				cwc.Cancel()
+			}
 		}
 
 		c.Cancel()
 	}()
 
+	if e := c.waitDepends(); e != nil {
+		return e
+	}
+
+	u.status.set(UintRunning, nil)
+
 	c.timeout()
 	defer c.cancelTimeout()
 
 	if e := u.handler(c); e != nil {
-		panic(e)
+		return e
 	}
 
 	c.Printf("uint success")
+	return nil
+}
+
+// 模块处理函数 panic 的值
+type PanicError struct {
+	Value any
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", pe.Value)
 }
@@ -0,0 +1,206 @@
+package ulog
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type AsyncPolicy uint8
+
+const (
+	AsyncBlock AsyncPolicy = iota // 队列满时阻塞调用者 (默认)
+	AsyncDrop                     // 队列满时丢弃日志, 并在之后输出丢弃数量
+)
+
+var ErrAsyncClosed = errors.New("async format closed")
+
+type AsyncOptions struct {
+	Capacity  int         // 队列容量, 默认 4096
+	BatchSize int         // 每批最多写入的日志数量, 默认 128
+	Policy    AsyncPolicy // 队列满时的策略
+}
+
+// 批量写入, 格式化接口实现后 AsyncFormat 会一次写入一批日志
+type BatchFormat interface {
+	Format
+	WriteBatch(logs []*Log)
+}
+
+// 可以刷新的格式化接口, Logger.Flush 和 Logger.Fatal 会调用
+type Flusher interface {
+	Flush() error
+}
+
+type asyncItem struct {
+	log  *Log
+	done chan struct{} // 不为空时为刷新标记
+}
+
+// 异步格式化, 在后台协程中写入被包装的格式化接口
+// @description 日志会被复制后放入有界队列, 调用者不会被慢速的写入阻塞 (除非队列满并且使用 AsyncBlock)
+type AsyncFormat struct {
+	opts     AsyncOptions
+	formats  []Format
+	queue    chan asyncItem
+	dropped  atomic.Uint64 // 丢弃的日志总数
+	reported uint64        // 已经输出的丢弃数量, 只在后台协程中使用
+	lock     sync.RWMutex  // 保护 closed, 关闭后不再写入队列
+	closed   bool
+	exited   chan struct{}
+}
+
+func NewAsyncFormat(opts AsyncOptions, formats ...Format) *AsyncFormat {
+	if opts.Capacity < 1 {
+		opts.Capacity = 4096
+	}
+
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 128
+	}
+
+	af := &AsyncFormat{
+		opts:    opts,
+		formats: formats,
+		queue:   make(chan asyncItem, opts.Capacity),
+		exited:  make(chan struct{}),
+	}
+
+	go af.worker()
+	return af
+}
+
+// 放入队列, 关闭后同步写入
+func (af *AsyncFormat) Write(log *Log) {
+	af.lock.RLock()
+	defer af.lock.RUnlock()
+
+	if af.closed {
+		af.write([]*Log{log})
+		return
+	}
+
+	item := asyncItem{log: log.Clone()}
+	if af.opts.Policy == AsyncDrop {
+		select {
+		case af.queue <- item:
+		default:
+			af.dropped.Add(1)
+		}
+		return
+	}
+
+	af.queue <- item
+}
+
+// 丢弃的日志总数
+func (af *AsyncFormat) Dropped() uint64 {
+	return af.dropped.Load()
+}
+
+// 等待队列中已有的日志写入完成, 然后刷新包装的格式化接口
+// @return 第一个刷新错误
+func (af *AsyncFormat) Flush() error {
+	af.lock.RLock()
+	if !af.closed {
+		done := make(chan struct{})
+		af.queue <- asyncItem{done: done}
+		<-done
+	}
+	af.lock.RUnlock()
+
+	return flushFormats(af.formats)
+}
+
+// 写入队列中的日志并停止后台协程, 之后的日志同步写入
+func (af *AsyncFormat) Close() error {
+	af.lock.Lock()
+	if af.closed {
+		af.lock.Unlock()
+		return ErrAsyncClosed
+	}
+
+	af.closed = true
+	close(af.queue)
+	af.lock.Unlock()
+
+	<-af.exited
+	return nil
+}
+
+func (af *AsyncFormat) worker() {
+	defer close(af.exited)
+
+	batch := make([]*Log, 0, af.opts.BatchSize)
+	for item := range af.queue {
+		flushes := []chan struct{}{}
+
+		for {
+			if item.done != nil {
+				flushes = append(flushes, item.done)
+			} else {
+				batch = append(batch, item.log)
+			}
+
+			if len(batch) >= af.opts.BatchSize {
+				break
+			}
+
+			var ok bool
+			select {
+			case item, ok = <-af.queue:
+			default:
+			}
+
+			if !ok {
+				break
+			}
+		}
+
+		af.reportDropped()
+		af.write(batch)
+		batch = batch[:0]
+
+		for _, done := range flushes {
+			close(done)
+		}
+	}
+
+	af.reportDropped()
+}
+
+func (af *AsyncFormat) write(logs []*Log) {
+	if len(logs) < 1 {
+		return
+	}
+
+	for _, f := range af.formats {
+		if bf, ok := f.(BatchFormat); ok {
+			bf.WriteBatch(logs)
+			continue
+		}
+
+		for _, log := range logs {
+			f.Write(log)
+		}
+	}
+}
+
+// 输出新增的丢弃数量
+func (af *AsyncFormat) reportDropped() {
+	dropped := af.dropped.Load()
+	if dropped == af.reported {
+		return
+	}
+
+	g := &Log{
+		Level:   LevelWarn,
+		Message: "ulog async format dropped logs",
+		Time:    time.Now(),
+		Fields:  []Field{{"dropped", dropped - af.reported}, {"total", dropped}},
+	}
+
+	af.reported = dropped
+	af.write([]*Log{g})
+}
@@ -0,0 +1,136 @@
+package ulog
+
+import (
+	"sync"
+	"testing"
+)
+
+// 阻塞写入, 直到 release 关闭
+type blockFormat struct {
+	lock    sync.Mutex
+	release chan struct{}
+	logs    []*Log
+	batches int
+}
+
+func (bf *blockFormat) Write(log *Log) {
+	bf.WriteBatch([]*Log{log})
+}
+
+func (bf *blockFormat) WriteBatch(logs []*Log) {
+	<-bf.release
+
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+
+	bf.batches++
+	bf.logs = append(bf.logs, logs...)
+}
+
+func TestAsyncFormat(t *testing.T) {
+	cf := &captureFormat{}
+	af := NewAsyncFormat(AsyncOptions{Capacity: 8}, cf)
+	l := NewLogger(af)
+
+	for i := 0; i < 20; i++ {
+		l.With("i", i).Info("message %d", i)
+	}
+
+	if e := l.Flush(); e != nil {
+		t.Fatal(e)
+	}
+
+	if len(cf.logs) != 20 {
+		t.Fatalf("logs: %d", len(cf.logs))
+	}
+
+	for i, g := range cf.logs {
+		if g.Fields[0].Value != i {
+			t.Fatalf("log %d: %+v", i, g)
+		}
+	}
+
+	if e := af.Close(); e != nil {
+		t.Fatal(e)
+	}
+
+	if af.Close() != ErrAsyncClosed {
+		t.Fatal("close twice")
+	}
+
+	// 关闭后同步写入
+	l.Info("after close")
+	if len(cf.logs) != 21 {
+		t.Fatalf("logs after close: %d", len(cf.logs))
+	}
+}
+
+func TestAsyncFormatDrop(t *testing.T) {
+	bf := &blockFormat{release: make(chan struct{})}
+	af := NewAsyncFormat(AsyncOptions{Capacity: 4, BatchSize: 64, Policy: AsyncDrop}, bf)
+	l := NewLogger(af)
+
+	for i := 0; i < 100; i++ {
+		l.Info("message %d", i)
+	}
+
+	// 后台协程最多取出一批 5 条日志, 队列中最多 4 条
+	if dropped := af.Dropped(); dropped < 91 {
+		t.Fatalf("dropped: %d", dropped)
+	}
+
+	close(bf.release)
+	af.Close()
+
+	written, reported := 0, uint64(0)
+	for _, g := range bf.logs {
+		if g.Level == LevelWarn {
+			reported += g.Fields[0].Value.(uint64)
+			continue
+		}
+		written++
+	}
+
+	if reported != af.Dropped() || written != 100-int(af.Dropped()) {
+		t.Fatalf("written: %d reported: %d dropped: %d", written, reported, af.Dropped())
+	}
+}
+
+func TestFatalFlush(t *testing.T) {
+	cf := &captureFormat{}
+	l := NewLogger(NewAsyncFormat(AsyncOptions{}, cf))
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("fatal should panic")
+		}
+
+		if len(cf.logs) != 1 || cf.logs[0].Message != "boom" {
+			t.Fatalf("logs: %+v", cf.logs)
+		}
+	}()
+
+	l.Fatal("boom")
+}
+
+func TestAsyncFormatNestedFlush(t *testing.T) {
+	bf := &blockFormat{release: make(chan struct{})}
+	inner := NewAsyncFormat(AsyncOptions{}, bf)
+	outer := NewAsyncFormat(AsyncOptions{}, inner)
+	l := NewLogger(outer)
+
+	l.Info("nested")
+	close(bf.release)
+
+	// 外层的刷新需要等待内层队列写入完成
+	if e := l.Flush(); e != nil {
+		t.Fatal(e)
+	}
+
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+
+	if len(bf.logs) != 1 || bf.logs[0].Message != "nested" {
+		t.Fatalf("logs: %+v", bf.logs)
+	}
+}
@@ -0,0 +1,136 @@
+// 日志目录查询工具, 按时间顺序读取 RotateWriter 写入的日志文件 (包括 .gz 轮转文件)
+//
+//	ulogq -name 2006-01-02.log -level warn,error -since 1h -grep 'timeout|refused' ./logs
+//	ulogq -name 2006-01-02.log -caller db.go:42 -f ./logs
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"time"
+
+	"uw/ulog"
+)
+
+func main() {
+	var (
+		name   = flag.String("name", "", "file name time format of RotateWriter, e.g. 2006-01-02.log, empty reads all files ordered by modification time")
+		level  = flag.String("level", "", "comma separated levels, e.g. warn,error, fatal is always shown, lines without a log header are skipped")
+		since  = flag.String("since", "", "start time, RFC 3339, \"2006-01-02 15:04:05\", \"2006-01-02\" or a duration before now, e.g. 30m, lines without a log header are skipped")
+		until  = flag.String("until", "", "end time (exclusive), same layouts as -since")
+		caller = flag.String("caller", "", "caller filter, e.g. db.go or db.go:42")
+		grep   = flag.String("grep", "", "regular expression matched against message and fields")
+		follow = flag.Bool("f", false, "wait for new logs and follow rotation, the last record is printed once the next one starts")
+		asJSON = flag.Bool("json", false, "print records as JSON lines")
+		source = flag.Bool("H", false, "print the file name of each record")
+	)
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <dir>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := ulog.ReaderOptions{FileFormat: *name, Caller: *caller, Follow: *follow}
+
+	if *level != "" {
+		if e := opts.Levels.UnmarshalText([]byte(*level)); e != nil {
+			fatal(e)
+		}
+	}
+
+	var e error
+	if opts.Since, e = parseTime(*since); e != nil {
+		fatal(e)
+	}
+
+	if opts.Until, e = parseTime(*until); e != nil {
+		fatal(e)
+	}
+
+	if *grep != "" {
+		if opts.Match, e = regexp.Compile(*grep); e != nil {
+			fatal(e)
+		}
+	}
+
+	r, e := ulog.NewReader(flag.Arg(0), opts)
+	if e != nil {
+		fatal(e)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		r.Close()
+	}()
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	jf := ulog.NewJSONFormat(nil)
+	for {
+		rec, e := r.Next()
+		if e == io.EOF {
+			return
+		} else if e != nil {
+			out.Flush()
+			fatal(e)
+		}
+
+		if *source {
+			out.WriteString(rec.Source + ": ")
+		}
+
+		if *asJSON {
+			out.WriteString(jf.Format(&rec.Log))
+		} else {
+			out.WriteString(rec.Raw + "\n")
+		}
+
+		// 跟随模式下及时输出
+		if *follow {
+			out.Flush()
+		}
+	}
+}
+
+// 解析时间, 支持 RFC 3339, 本地时间和相对于现在的时长
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if d, e := time.ParseDuration(s); e == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	if t, e := time.Parse(time.RFC3339Nano, s); e == nil {
+		return t, nil
+	}
+
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, e := time.ParseInLocation(layout, s, time.Local); e == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time: %s", s)
+}
+
+func fatal(e error) {
+	os.Stderr.WriteString("ulogq: " + e.Error() + "\n")
+	os.Exit(1)
+}
@@ -0,0 +1,28 @@
+package ulog
+
+import "context"
+
+type loggerContextKey struct{}
+
+// 将日志放入上下文, 下游使用 FromContext 获取, 日志的字段会出现在下游的每一条日志中
+func WithContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, l)
+}
+
+// 上下文中的日志
+// @return 没有时返回全局日志
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerContextKey{}).(*Logger); ok && l != nil {
+			return l
+		}
+	}
+
+	return globalLogger
+}
+
+// 为上下文中的日志添加字段, 例如 ContextWith(ctx, "request_id", id)
+// @param args 键值对, 参考 Logger.With
+func ContextWith(ctx context.Context, args ...interface{}) context.Context {
+	return WithContext(ctx, FromContext(ctx).With(args...))
+}
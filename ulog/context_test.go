@@ -0,0 +1,24 @@
+package ulog
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContextLogger(t *testing.T) {
+	if FromContext(context.Background()) != GlobalLogger() {
+		t.Fatal("default logger")
+	}
+
+	cf := &captureFormat{}
+	ctx := WithContext(context.Background(), NewLogger(cf).Named("web"))
+	ctx = ContextWith(ctx, "request_id", "r1")
+	ctx = ContextWith(ctx, "query_id", 2)
+
+	FromContext(ctx).Info("query done")
+
+	g := cf.logs[0]
+	if g.Name != "web" || len(g.Fields) != 2 || g.Fields[0] != F("request_id", "r1") || g.Fields[1] != F("query_id", 2) {
+		t.Fatalf("log: %+v", g)
+	}
+}
@@ -41,15 +41,27 @@ func Warn(format string, args ...interface{}) {
 }
 
 func Error(format string, args ...interface{}) {
-	globalLogger.Log(LevelError, defaultCaller, format+"\r\nError Stack:\r\n%s",
-		append(args, Stack(1000, 1))...)
+	globalLogger.logError(LevelError, defaultCaller, format, args)
 }
 
 func Fatal(format string, args ...interface{}) {
-	globalLogger.Log(LevelFatal, defaultCaller, format, args...)
+	globalLogger.logError(LevelFatal, defaultCaller, format, args)
+	globalLogger.Flush()
+
+	args, _ = splitArgs(format, args)
 	panic(fmt.Sprintf(format, args...))
 }
 
+// 全局日志的命名子日志
+func Named(name string) *Logger {
+	return globalLogger.Named(name)
+}
+
+// 全局日志的子日志
+func With(args ...interface{}) *Logger {
+	return globalLogger.With(args...)
+}
+
 func Timer() *TimerLogger {
 	return globalLogger.Timer()
 }
@@ -58,6 +70,16 @@ func Progress(length int, total float64, unit string) *ProgressLogger {
 	return globalLogger.Progress(length, total, unit)
 }
 
+// 设置全局日志的重复日志采样
+func SetSampling(opts SampleOptions) {
+	globalLogger.SetSampling(opts)
+}
+
+// 刷新全局日志的格式化接口
+func Flush() error {
+	return globalLogger.Flush()
+}
+
 func Register(format Format) {
 	globalLogger.Register(format)
 }
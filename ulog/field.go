@@ -0,0 +1,137 @@
+package ulog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const badKey = "!BADKEY" // 没有键的值
+
+// 结构化字段
+type Field struct {
+	Key   string
+	Value any
+}
+
+// 创建字段
+func F(key string, value any) Field {
+	return Field{Key: key, Value: value}
+}
+
+// 复制日志, Log 对象来自对象池, 需要在 Write 返回后使用时必须复制
+func (g *Log) Clone() *Log {
+	c := *g
+	c.Fields = append([]Field(nil), g.Fields...)
+	c.Stack = append([]Frame(nil), g.Stack...)
+	c.Causes = append([]Cause(nil), g.Causes...)
+	return &c
+}
+
+// 将键值对参数转换为字段
+// @description 参数可以是 Field, 或者 string 键后跟值, 没有键的值使用 !BADKEY 作为键
+func argsToFields(fields []Field, args []interface{}) []Field {
+	for i := 0; i < len(args); i++ {
+		switch v := args[i].(type) {
+		case Field:
+			fields = append(fields, v)
+		case string:
+			if i+1 < len(args) {
+				fields = append(fields, Field{Key: v, Value: args[i+1]})
+				i++
+				continue
+			}
+
+			fields = append(fields, Field{Key: badKey, Value: v})
+		default:
+			fields = append(fields, Field{Key: badKey, Value: v})
+		}
+	}
+
+	return fields
+}
+
+// 将参数分为格式化参数和字段参数
+// @description 格式化字符串中的占位符依次消耗参数, 剩余的参数作为键值对字段
+// 使用显式参数索引 (例如 %[1]s) 时所有参数都作为格式化参数
+func splitArgs(format string, args []interface{}) ([]interface{}, []interface{}) {
+	if len(args) < 1 {
+		return args, nil
+	}
+
+	n := countVerbs(format)
+	if n < 0 || n >= len(args) {
+		return args, nil
+	}
+
+	return args[:n], args[n:]
+}
+
+// 格式化字符串消耗的参数数量, 使用显式参数索引时返回 -1
+func countVerbs(format string) int {
+	n := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+
+		// 跳过标志, 宽度和精度, * 也会消耗参数
+		for i++; i < len(format); i++ {
+			c := format[i]
+			if c == '[' {
+				return -1
+			}
+
+			if c == '*' {
+				n++
+				continue
+			}
+
+			if strings.IndexByte("+-# 0123456789.", c) < 0 {
+				break
+			}
+		}
+
+		if i < len(format) && format[i] != '%' {
+			n++
+		}
+	}
+
+	return n
+}
+
+// 以 key=value 格式输出字段, 包含空格/引号/等号的值会被加上引号
+func formatFields(fields []Field, key func(string) string) string {
+	if len(fields) < 1 {
+		return ""
+	}
+
+	b := &strings.Builder{}
+	for _, f := range fields {
+		b.WriteByte(' ')
+		b.WriteString(key(f.Key))
+		b.WriteByte('=')
+
+		s := fieldString(f.Value)
+		if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+			s = strconv.Quote(s)
+		}
+
+		b.WriteString(s)
+	}
+
+	return b.String()
+}
+
+func fieldString(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case error:
+		return val.Error()
+	case fmt.Stringer:
+		return val.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,119 @@
+package ulog
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type captureFormat struct {
+	logs []*Log
+}
+
+func (cf *captureFormat) Write(log *Log) {
+	cf.logs = append(cf.logs, log.Clone())
+}
+
+func TestLoggerFields(t *testing.T) {
+	cf := &captureFormat{}
+	l := NewLogger(cf)
+
+	child := l.With("req_id", 42).With(F("user", "qaq"))
+	child.Info("login %s from %d%%", "web", 3, "ip", "127.0.0.1", "dangling")
+	l.Info("plain %s", "msg")
+
+	if len(cf.logs) != 2 {
+		t.Fatalf("logs: %d", len(cf.logs))
+	}
+
+	g := cf.logs[0]
+	if g.Message != "login web from 3%" {
+		t.Fatalf("message: %q", g.Message)
+	}
+
+	want := []Field{{"req_id", 42}, {"user", "qaq"}, {"ip", "127.0.0.1"}, {badKey, "dangling"}}
+	if len(g.Fields) != len(want) {
+		t.Fatalf("fields: %+v", g.Fields)
+	}
+	for i := range want {
+		if g.Fields[i] != want[i] {
+			t.Fatalf("field %d: %+v", i, g.Fields[i])
+		}
+	}
+
+	if len(cf.logs[1].Fields) != 0 || !strings.HasSuffix(g.File, "field_test.go") {
+		t.Fatalf("plain log: %+v", cf.logs[1])
+	}
+}
+
+func TestCountVerbs(t *testing.T) {
+	for format, want := range map[string]int{
+		"no verbs":       0,
+		"%s %d":          2,
+		"100%% %v":       1,
+		"%-5.2f %*d %+v": 4,
+		"%[1]s":          -1,
+		"trailing %":     0,
+	} {
+		if n := countVerbs(format); n != want {
+			t.Fatalf("%q: %d, want %d", format, n, want)
+		}
+	}
+}
+
+func TestFormatFields(t *testing.T) {
+	g := &Log{
+		Level:   LevelInfo,
+		File:    "/src/main.go",
+		Line:    12,
+		Message: SetANSI(ANSI.Bold, "hello"),
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Fields: []Field{
+			{"msg", "shadow"},
+			{"n", 1},
+			{"err", errors.New("bad thing")},
+			{"d", time.Second},
+			{"tags", []string{"a", "b"}},
+		},
+	}
+
+	df := NewDefaultFormat(nil)
+	df.SetLocation(time.UTC)
+	if s := df.PureFormat(g); !strings.HasSuffix(s, `msg=shadow n=1 err="bad thing" d=1s tags="[a b]"`+"\r\n") {
+		t.Fatalf("pure: %q", s)
+	}
+
+	jf := NewJSONFormat(nil)
+	jf.SetLocation(time.UTC)
+
+	line := jf.Format(g)
+	if !strings.HasSuffix(line, "}\n") || strings.Count(line, "\n") != 1 {
+		t.Fatalf("json line: %q", line)
+	}
+
+	v := map[string]any{}
+	if e := json.Unmarshal([]byte(line), &v); e != nil {
+		t.Fatal(e)
+	}
+
+	for k, want := range map[string]any{
+		"time":       "2024-01-02T03:04:05Z",
+		"level":      "INFO",
+		"caller":     "main.go:12",
+		"msg":        "hello",
+		"fields.msg": "shadow",
+		"n":          float64(1),
+		"err":        "bad thing",
+		"d":          "1s",
+	} {
+		if v[k] != want {
+			t.Fatalf("json %s: %v", k, v[k])
+		}
+	}
+
+	if tags, ok := v["tags"].([]any); !ok || len(tags) != 2 {
+		t.Fatalf("json tags: %v", v["tags"])
+	}
+}
@@ -3,6 +3,7 @@ package ulog
 import (
 	"fmt"
 	"path"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,15 +17,18 @@ var (
 type DefaultFormat struct {
 	location *time.Location
 	writer   func(s string)
-	level    Level
+	level    atomic.Uint32 // 运行时可以修改
+	stack    StackMode
 }
 
 func NewDefaultFormat(f func(s string)) *DefaultFormat {
-	return &DefaultFormat{
+	sw := &DefaultFormat{
 		writer:   f,
 		location: time.Local,
-		level:    DefaultLevel,
 	}
+
+	sw.level.Store(uint32(DefaultLevel))
+	return sw
 }
 
 func (sw *DefaultFormat) SetWriter(f func(s string)) {
@@ -35,22 +39,27 @@ func (sw *DefaultFormat) SetLocation(location *time.Location) {
 	sw.location = location
 }
 
+// 调用栈和错误链的渲染方式, 默认 StackAuto 在消息之后输出多行文本
+func (sw *DefaultFormat) SetStackMode(mode StackMode) {
+	sw.stack = mode
+}
+
 func (sw *DefaultFormat) GetLevel() Level {
-	return sw.level
+	return Level(sw.level.Load())
 }
 
 func (sw *DefaultFormat) SetLevel(val Level) {
-	sw.level = val
+	sw.level.Store(uint32(val))
 }
 
 func (sw *DefaultFormat) Write(log *Log) {
-	if sw.level&log.Level != 0 || LevelFatal&log.Level != 0 {
+	if sw.GetLevel()&log.Level != 0 || LevelFatal&log.Level != 0 {
 		sw.writer(sw.Format(log))
 	}
 }
 
 func (sw *DefaultFormat) Format(log *Log) string {
-	if sw.level&LevelColor != 0 {
+	if sw.GetLevel()&LevelColor != 0 {
 		return sw.PrettyFormat(log)
 	}
 
@@ -58,23 +67,42 @@ func (sw *DefaultFormat) Format(log *Log) string {
 }
 
 func (sw *DefaultFormat) PureFormat(log *Log) string {
-	return fmt.Sprintf("%s %s %s:%d %s\r\n",
+	return fmt.Sprintf("%s %s %s:%d %s%s%s%s\r\n",
 		LevelName(log.Level),
 		log.Time.In(sw.location).Format("06-01-02 15:04:05.000"),
 		path.Base(log.File), log.Line,
-		log.Message,
+		logName(log, func(s string) string { return s }), log.Message, formatFields(log.Fields, func(k string) string { return k }),
+		sw.formatStack(log),
 	)
 }
 
 func (sw *DefaultFormat) PrettyFormat(log *Log) string {
-	return fmt.Sprintf("%s %s %s %s\r\n",
+	return fmt.Sprintf("%s %s %s %s%s%s%s\r\n",
 		levelPretty(log.Level),
 		SetANSI(ANSI.Grey, log.Time.In(sw.location).Format("06-01-02 15:04:05.000")),
 		SetANSI(ANSI.Magenta, fmt.Sprintf("%s:%d", path.Base(log.File), log.Line)),
-		log.Message,
+		logName(log, func(s string) string { return SetANSI(ANSI.Cyan, s) }), log.Message, formatFields(log.Fields, func(k string) string { return SetANSI(ANSI.Cyan, k) }),
+		sw.formatStack(log),
 	)
 }
 
+func (sw *DefaultFormat) formatStack(log *Log) string {
+	if sw.stack == StackNone {
+		return ""
+	}
+
+	return formatStack(log)
+}
+
+// 模块名称前缀, 例如 "[upg.pool] ", 未命名时为空
+func logName(log *Log, style func(s string) string) string {
+	if log.Name == "" {
+		return ""
+	}
+
+	return style("["+log.Name+"]") + " "
+}
+
 func levelPretty(level Level) string {
 	switch level {
 	case LevelTrace:
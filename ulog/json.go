@@ -0,0 +1,163 @@
+package ulog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// JSON 格式化, 每条日志一行 JSON 对象
+// @description 固定包含 time/level/caller/msg, 命名日志包含 logger, 字段按顺序追加, 与固定键重名的字段加上 fields. 前缀,
+// 调用栈和错误链在最后输出为 stack 和 causes.
+// 消息中的 ANSI 颜色会被清除
+type JSONFormat struct {
+	location   *time.Location
+	timeFormat string
+	writer     func(s string)
+	level      atomic.Uint32
+	stack      StackMode
+}
+
+func NewJSONFormat(f func(s string)) *JSONFormat {
+	jf := &JSONFormat{
+		writer:     f,
+		location:   time.Local,
+		timeFormat: time.RFC3339Nano,
+	}
+
+	jf.level.Store(uint32(DefaultLevelNoColor))
+	return jf
+}
+
+func (jf *JSONFormat) SetWriter(f func(s string)) {
+	jf.writer = f
+}
+
+func (jf *JSONFormat) SetLocation(location *time.Location) {
+	jf.location = location
+}
+
+// 时间格式, 默认 time.RFC3339Nano
+func (jf *JSONFormat) SetTimeFormat(layout string) {
+	jf.timeFormat = layout
+}
+
+// 调用栈和错误链的渲染方式, 默认 StackAuto 输出为数组, StackInline 时 stack 为多行文本
+func (jf *JSONFormat) SetStackMode(mode StackMode) {
+	jf.stack = mode
+}
+
+func (jf *JSONFormat) GetLevel() Level {
+	return Level(jf.level.Load())
+}
+
+func (jf *JSONFormat) SetLevel(val Level) {
+	jf.level.Store(uint32(val))
+}
+
+func (jf *JSONFormat) Write(log *Log) {
+	if jf.GetLevel()&log.Level != 0 || LevelFatal&log.Level != 0 {
+		jf.writer(jf.Format(log))
+	}
+}
+
+var jsonReservedKeys = map[string]bool{"time": true, "level": true, "caller": true, "logger": true, "msg": true,
+	"stack": true, "causes": true}
+
+func (jf *JSONFormat) Format(log *Log) string {
+	b := &bytes.Buffer{}
+
+	b.WriteString(`{"time":`)
+	writeJSONString(b, log.Time.In(jf.location).Format(jf.timeFormat))
+	b.WriteString(`,"level":`)
+	writeJSONString(b, LevelName(log.Level))
+	b.WriteString(`,"caller":`)
+	writeJSONString(b, path.Base(log.File)+":"+strconv.Itoa(log.Line))
+	if log.Name != "" {
+		b.WriteString(`,"logger":`)
+		writeJSONString(b, log.Name)
+	}
+	b.WriteString(`,"msg":`)
+	writeJSONString(b, CleanANSI(log.Message))
+
+	for _, f := range log.Fields {
+		key := f.Key
+		if jsonReservedKeys[key] {
+			key = "fields." + key
+		}
+
+		b.WriteByte(',')
+		writeJSONString(b, key)
+		b.WriteByte(':')
+		writeJSONValue(b, f.Value)
+	}
+
+	if jf.stack != StackNone {
+		jf.writeStack(b, log)
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func (jf *JSONFormat) writeStack(b *bytes.Buffer, log *Log) {
+	if len(log.Stack) > 0 {
+		b.WriteString(`,"stack":`)
+		if jf.stack == StackInline {
+			stack := &strings.Builder{}
+			for i, f := range log.Stack {
+				if i > 0 {
+					stack.WriteString("\n")
+				}
+				stack.WriteString(f.Function + "\n\t" + f.File + ":" + strconv.Itoa(f.Line))
+			}
+			writeJSONString(b, stack.String())
+		} else {
+			data, _ := json.Marshal(log.Stack)
+			b.Write(data)
+		}
+	}
+
+	if len(log.Causes) > 0 {
+		data, _ := json.Marshal(log.Causes)
+		b.WriteString(`,"causes":`)
+		b.Write(data)
+	}
+}
+
+func writeJSONString(b *bytes.Buffer, s string) {
+	data, _ := json.Marshal(s)
+	b.Write(data)
+}
+
+// 写入字段值, error 和无法序列化的值使用字符串
+func writeJSONValue(b *bytes.Buffer, v any) {
+	switch val := v.(type) {
+	case nil:
+		b.WriteString("null")
+		return
+	case error:
+		writeJSONString(b, val.Error())
+		return
+	case time.Duration:
+		writeJSONString(b, val.String())
+		return
+	case json.Marshaler:
+	case fmt.Stringer:
+		writeJSONString(b, val.String())
+		return
+	}
+
+	data, e := json.Marshal(v)
+	if e != nil {
+		writeJSONString(b, fmt.Sprint(v))
+		return
+	}
+
+	b.Write(data)
+}
@@ -0,0 +1,286 @@
+package ulog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"uw/utoml"
+)
+
+// 模块等级的环境变量, 例如 ULOG_LEVELS=upg=warn,uweb=debug,upg.pool=trace
+const LevelsEnv = "ULOG_LEVELS"
+
+// 模块等级, 名称为点分隔的层级, 最长匹配的前缀决定等级阈值, 空名称为根
+type moduleLevels struct {
+	lock    sync.RWMutex
+	levels  map[string]Level
+	version atomic.Uint64 // 每次修改递增, 用于 Logger 的等级缓存
+}
+
+var globalLevels = &moduleLevels{levels: map[string]Level{}}
+
+func init() {
+	if e := LoadLevelsEnv(LevelsEnv); e != nil {
+		os.Stderr.WriteString("ulog: " + e.Error() + "\n")
+	}
+}
+
+// 设置模块等级阈值
+// @description 低于阈值的日志在格式化之前被丢弃, LevelPrintf 和 LevelFatal 不受阈值限制,
+// LevelMuted 只保留 LevelFatal, 多个等级的组合 (例如 DefaultLevel) 使用其中最低的等级
+// @param name 模块名称, 例如 "upg.pool", 空名称为根等级, 也作用于未命名的日志
+// @param level 等级阈值
+func SetModuleLevel(name string, level Level) {
+	globalLevels.lock.Lock()
+	defer globalLevels.lock.Unlock()
+
+	globalLevels.levels[name] = thresholdLevel(level)
+	globalLevels.version.Add(1)
+}
+
+// 删除模块等级, 之后使用更短前缀的等级
+func UnsetModuleLevel(name string) {
+	globalLevels.lock.Lock()
+	defer globalLevels.lock.Unlock()
+
+	delete(globalLevels.levels, name)
+	globalLevels.version.Add(1)
+}
+
+// 替换所有模块等级
+func SetModuleLevels(levels map[string]Level) {
+	globalLevels.lock.Lock()
+	defer globalLevels.lock.Unlock()
+
+	globalLevels.levels = make(map[string]Level, len(levels))
+	for name, level := range levels {
+		globalLevels.levels[name] = thresholdLevel(level)
+	}
+
+	globalLevels.version.Add(1)
+}
+
+// 当前配置的模块等级
+func ModuleLevels() map[string]Level {
+	globalLevels.lock.RLock()
+	defer globalLevels.lock.RUnlock()
+
+	levels := make(map[string]Level, len(globalLevels.levels))
+	for name, level := range globalLevels.levels {
+		levels[name] = level
+	}
+
+	return levels
+}
+
+// 模块的等级阈值
+// @param name 模块名称
+// @return 最长匹配前缀的等级, 没有匹配时 ok 为 false
+func ModuleLevel(name string) (level Level, ok bool) {
+	globalLevels.lock.RLock()
+	defer globalLevels.lock.RUnlock()
+
+	return globalLevels.lookup(name)
+}
+
+func (ml *moduleLevels) lookup(name string) (Level, bool) {
+	for {
+		if level, ok := ml.levels[name]; ok {
+			return level, true
+		}
+
+		if name == "" {
+			return LevelMuted, false
+		}
+
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			name = ""
+		} else {
+			name = name[:i]
+		}
+	}
+}
+
+// 等级组合取最低的等级, LevelPrintf 只在单独出现时作为阈值
+func thresholdLevel(level Level) Level {
+	if level&^LevelPrintf != 0 {
+		level &^= LevelPrintf
+	}
+
+	return level & -level
+}
+
+// 等级是否通过阈值
+func levelPass(threshold, level Level) bool {
+	if level == LevelFatal {
+		return true
+	}
+
+	if threshold == LevelMuted {
+		return false
+	}
+
+	return level == LevelPrintf || level >= threshold
+}
+
+// 解析模块等级, 格式为 name=level, 使用逗号分隔, 没有名称的等级为根等级
+// @description 例如 "info,upg=warn,uweb=debug"
+func ParseModuleLevels(s string) (map[string]Level, error) {
+	levels := map[string]Level{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(item, "=")
+		if !found {
+			name, value = "", item
+		}
+
+		level, e := ParseLevel(value)
+		if e != nil {
+			return nil, fmt.Errorf("module %q: %w", name, e)
+		}
+
+		levels[strings.TrimSpace(name)] = level
+	}
+
+	return levels, nil
+}
+
+// 从环境变量加载模块等级, 环境变量为空时不修改
+func LoadLevelsEnv(env string) error {
+	s := os.Getenv(env)
+	if s == "" {
+		return nil
+	}
+
+	levels, e := ParseModuleLevels(s)
+	if e != nil {
+		return fmt.Errorf("%s: %w", env, e)
+	}
+
+	SetModuleLevels(levels)
+	return nil
+}
+
+// 从 TOML 的表加载模块等级
+// @description 嵌套的表会展开为点分隔的模块名称, 同时设置父模块时子模块需要使用带引号的键, 例如
+//
+//	[log.levels]
+//	"" = "info"
+//	upg = "warn"
+//	"upg.pool" = "debug"
+//	uweb.router = "trace"
+//
+// @param data TOML 文档
+// @param section 表路径, 例如 "log.levels", 为空使用整个文档
+func LoadLevelsTOML(data []byte, section string) error {
+	doc := map[string]interface{}{}
+	if e := utoml.Unmarshal(data, &doc); e != nil {
+		return e
+	}
+
+	if section != "" {
+		for _, key := range strings.Split(section, ".") {
+			table, ok := doc[key].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("toml section %q not found", section)
+			}
+
+			doc = table
+		}
+	}
+
+	levels := map[string]Level{}
+	if e := flattenLevels(levels, "", doc); e != nil {
+		return e
+	}
+
+	SetModuleLevels(levels)
+	return nil
+}
+
+func flattenLevels(levels map[string]Level, prefix string, table map[string]interface{}) error {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+
+		switch v := table[key].(type) {
+		case string:
+			level, e := ParseLevel(v)
+			if e != nil {
+				return fmt.Errorf("module %q: %w", name, e)
+			}
+
+			levels[name] = level
+		case map[string]interface{}:
+			if e := flattenLevels(levels, name, v); e != nil {
+				return e
+			}
+		default:
+			return fmt.Errorf("module %q: level must be a string", name)
+		}
+	}
+
+	return nil
+}
+
+// 实现 encoding.TextUnmarshaler, 可以在配置结构体中使用 map[string]ulog.Level,
+// 逗号分隔的等级组合 (参考 ParseLevel) 或者数字形式的位掩码
+// @description 不实现 encoding.TextMarshaler, 编码时仍然是数字, 与已有的 JSON/TOML 数据兼容
+func (level *Level) UnmarshalText(text []byte) error {
+	// 数字形式的等级位掩码, 例如 TOML 中的整数
+	if n, e := strconv.ParseUint(string(text), 10, 8); e == nil {
+		*level = Level(n)
+		return nil
+	}
+
+	var v Level
+	for _, name := range strings.Split(string(text), ",") {
+		l, e := ParseLevel(name)
+		if e != nil {
+			return e
+		}
+
+		v |= l
+	}
+
+	*level = v
+	return nil
+}
+
+// 实现 json.Unmarshaler, 同时支持数字和等级名称
+// @description 只实现 UnmarshalText 时 encoding/json 无法解码数字形式的等级
+func (level *Level) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if e := json.Unmarshal(data, &s); e != nil {
+			return e
+		}
+
+		return level.UnmarshalText([]byte(s))
+	}
+
+	return level.UnmarshalText(data)
+}
@@ -0,0 +1,117 @@
+package ulog
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"uw/utoml"
+)
+
+func TestModuleLevels(t *testing.T) {
+	t.Cleanup(func() { SetModuleLevels(nil) })
+
+	cf := &captureFormat{}
+	l := NewLogger(cf)
+	pool := l.Named("upg").Named("pool")
+	conn := pool.With("id", 1).Named("conn")
+	web := l.Named("uweb")
+
+	levels, e := ParseModuleLevels("warn, upg=error, upg.pool=debug")
+	if e != nil {
+		t.Fatal(e)
+	}
+	SetModuleLevels(levels)
+
+	conn.Debug("conn debug")
+	conn.Trace("conn trace")
+	l.Named("upg").Warn("upg warn")
+	web.Info("web info")
+	web.Warn("web warn")
+	web.Printf("web printf")
+	l.Trace("unnamed trace")
+
+	want := []string{"conn debug", "web warn", "web printf"}
+	if len(cf.logs) != len(want) {
+		t.Fatalf("logs: %d", len(cf.logs))
+	}
+	for i := range want {
+		if cf.logs[i].Message != want[i] {
+			t.Fatalf("log %d: %q", i, cf.logs[i].Message)
+		}
+	}
+
+	if cf.logs[0].Name != "upg.pool.conn" || cf.logs[0].Fields[0].Value != 1 {
+		t.Fatalf("named log: %+v", cf.logs[0])
+	}
+
+	// 运行时修改
+	cf.logs = nil
+	SetModuleLevel("uweb", LevelMuted)
+	UnsetModuleLevel("upg.pool")
+	web.Error("web error")
+	conn.Error("conn error")
+	conn.Warn("conn warn")
+
+	if len(cf.logs) != 1 || !strings.HasPrefix(cf.logs[0].Message, "conn error") {
+		t.Fatalf("logs after change: %+v", cf.logs)
+	}
+
+	if level, ok := ModuleLevel("upg.pool.conn"); !ok || level != LevelError {
+		t.Fatalf("module level: %s %v", LevelName(level), ok)
+	}
+}
+
+func TestLoadLevelsTOML(t *testing.T) {
+	t.Cleanup(func() { SetModuleLevels(nil) })
+
+	data := []byte(`
+[log.levels]
+"" = "info"
+upg = "warn"
+"upg.pool" = "all"
+uweb.router = "trace"
+`)
+
+	if e := LoadLevelsTOML(data, "log.levels"); e != nil {
+		t.Fatal(e)
+	}
+
+	levels := ModuleLevels()
+	if len(levels) != 4 || levels[""] != LevelInfo || levels["upg"] != LevelWarn ||
+		levels["upg.pool"] != LevelTrace || levels["uweb.router"] != LevelTrace {
+		t.Fatalf("levels: %v", levels)
+	}
+
+	if e := LoadLevelsTOML(data, "log.missing"); e == nil {
+		t.Fatal("missing section")
+	}
+
+	// 配置结构体中使用 Level
+	config := struct {
+		Levels map[string]Level `toml:"levels"`
+	}{}
+	if e := utoml.Unmarshal([]byte("[levels]\nuweb = \"debug\"\n"), &config); e != nil {
+		t.Fatal(e)
+	}
+
+	if config.Levels["uweb"] != LevelDebug {
+		t.Fatalf("config: %v", config.Levels)
+	}
+
+	// 编码仍然是数字, 解码同时支持数字和等级名称
+	if e := utoml.Unmarshal([]byte("[levels]\nupg = 8\n"), &config); e != nil || config.Levels["upg"] != LevelDebug {
+		t.Fatalf("toml number: %v %v", config.Levels, e)
+	}
+
+	encoded, _ := json.Marshal(map[string]Level{"upg": LevelWarn | LevelError})
+	if string(encoded) != `{"upg":96}` {
+		t.Fatalf("json: %s", encoded)
+	}
+
+	levels = map[string]Level{}
+	if e := json.Unmarshal([]byte(`{"upg":96,"uweb":"warn,error"}`), &levels); e != nil ||
+		levels["upg"] != LevelWarn|LevelError || levels["uweb"] != LevelWarn|LevelError {
+		t.Fatalf("json decode: %v %v", levels, e)
+	}
+}
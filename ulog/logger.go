@@ -3,7 +3,9 @@ package ulog
 import (
 	"fmt"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,6 +27,12 @@ const (
 type Logger struct {
 	logPool    sync.Pool
 	formatList []Format
+	formatLock sync.RWMutex            // 运行时注册格式化接口
+	parent     *Logger                 // 子日志的根日志, 子日志使用根日志的对象池和格式化接口
+	fields     []Field                 // 子日志的字段, 添加到每一条日志
+	name       string                  // 模块名称, 点分隔的层级
+	levelCache atomic.Uint64           // 模块等级缓存, 版本 << 16 | 是否匹配 << 8 | 等级
+	sampler    atomic.Pointer[sampler] // 重复日志采样, 只在根日志设置
 }
 
 type Log struct {
@@ -33,6 +41,10 @@ type Log struct {
 	Line    int       // 追溯行号
 	Message string    // 消息
 	Time    time.Time // 时间
+	Fields  []Field   // 结构化字段
+	Name    string    // 模块名称, 未命名时为空
+	Stack   []Frame   // 调用栈, Error 和 Fatal 日志才有
+	Causes  []Cause   // 参数和字段中错误的错误链
 }
 
 type Format interface {
@@ -62,6 +74,52 @@ func LevelName(level Level) string {
 	}
 }
 
+// 解析等级名称, 不区分大小写
+// @description 支持 LevelName 返回的名称, 以及 color (彩色输出), all/default (DefaultLevel)
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "MUTED":
+		return LevelMuted, nil
+	case "PRINTF":
+		return LevelPrintf, nil
+	case "TRACE":
+		return LevelTrace, nil
+	case "DEBUG":
+		return LevelDebug, nil
+	case "INFO":
+		return LevelInfo, nil
+	case "WARN", "WARNING":
+		return LevelWarn, nil
+	case "ERROR":
+		return LevelError, nil
+	case "FATAL":
+		return LevelFatal, nil
+	case "COLOR":
+		return LevelColor, nil
+	case "ALL", "DEFAULT":
+		return DefaultLevel, nil
+	default:
+		return LevelMuted, fmt.Errorf("unknown level: %s", name)
+	}
+}
+
+// 等级组合的小写名称, 例如 DefaultLevel 为 printf trace debug info warn error color
+// @description LevelFatal 总是输出, 并且与 LevelColor 的值相同, 只显示为 color
+func LevelNames(level Level) []string {
+	names := []string{}
+	for _, l := range []Level{LevelPrintf, LevelTrace, LevelDebug, LevelInfo, LevelWarn, LevelError} {
+		if level&l != 0 {
+			names = append(names, strings.ToLower(LevelName(l)))
+		}
+	}
+
+	if level&LevelColor != 0 {
+		names = append(names, "color")
+	}
+
+	return names
+}
+
 func NewLogger(formatList ...Format) *Logger {
 	l := &Logger{
 		logPool: sync.Pool{
@@ -75,29 +133,206 @@ func NewLogger(formatList ...Format) *Logger {
 	return l
 }
 
+// 子日志, 共享格式化接口, 每一条日志都会带上字段
+// @param args 键值对, 参考 Logger.Log
+func (l *Logger) With(args ...interface{}) *Logger {
+	return &Logger{
+		parent: l.root(),
+		fields: argsToFields(append([]Field(nil), l.fields...), args),
+		name:   l.name,
+	}
+}
+
+// 命名子日志, 名称追加到当前名称之后, 例如 Named("upg").Named("pool") 为 "upg.pool"
+// @description 命名日志受模块等级限制, 参考 SetModuleLevel
+func (l *Logger) Named(name string) *Logger {
+	if l.name != "" {
+		name = l.name + "." + name
+	}
+
+	return &Logger{
+		parent: l.root(),
+		fields: l.fields,
+		name:   name,
+	}
+}
+
+// 模块名称
+func (l *Logger) Name() string {
+	return l.name
+}
+
+// 等级是否通过模块等级阈值, 没有配置模块等级时总是通过
+func (l *Logger) Enabled(level Level) bool {
+	version := globalLevels.version.Load()
+
+	cache := l.levelCache.Load()
+	if cache>>16 != version {
+		globalLevels.lock.RLock()
+		threshold, ok := globalLevels.lookup(l.name)
+		version = globalLevels.version.Load()
+		globalLevels.lock.RUnlock()
+
+		cache = version<<16 | uint64(threshold)
+		if ok {
+			cache |= 1 << 8
+		}
+
+		l.levelCache.Store(cache)
+	}
+
+	if cache&(1<<8) == 0 {
+		return true
+	}
+
+	return levelPass(Level(cache), level)
+}
+
+func (l *Logger) root() *Logger {
+	if l.parent != nil {
+		return l.parent
+	}
+
+	return l
+}
+
 func (l *Logger) Writer(g *Log) {
-	defer l.logPool.Put(g)
+	l = l.root()
+
+	defer func() {
+		g.Stack, g.Causes = g.Stack[:0], g.Causes[:0]
+		l.logPool.Put(g)
+	}()
+
+	l.formatLock.RLock()
+	defer l.formatLock.RUnlock()
+
 	for i := 0; i < len(l.formatList); i++ {
 		l.formatList[i].Write(g)
 	}
 }
 
 func (l *Logger) Register(format Format) {
+	l = l.root()
+	l.formatLock.Lock()
+	defer l.formatLock.Unlock()
+
 	l.formatList = append(l.formatList, format)
 }
 
+// 已注册的格式化接口
+func (l *Logger) Formats() []Format {
+	l = l.root()
+	l.formatLock.RLock()
+	defer l.formatLock.RUnlock()
+
+	return append([]Format(nil), l.formatList...)
+}
+
+// 删除一个格式化接口, 其它格式化接口的顺序不变
+// @return 是否已注册
+func (l *Logger) UnregisterFormat(format Format) bool {
+	l = l.root()
+	l.formatLock.Lock()
+	defer l.formatLock.Unlock()
+
+	for i := range l.formatList {
+		if l.formatList[i] == format {
+			l.formatList = append(l.formatList[:i:i], l.formatList[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
 func (l *Logger) Unregister() {
+	l = l.root()
+	l.formatLock.Lock()
+	defer l.formatLock.Unlock()
+
 	l.formatList = []Format{}
 }
 
+// 刷新实现了 Flusher 的格式化接口, 例如 AsyncFormat, 包装的格式化接口也会被刷新
+// @return 第一个刷新错误
+func (l *Logger) Flush() error {
+	l = l.root()
+	l.formatLock.RLock()
+	defer l.formatLock.RUnlock()
+
+	return flushFormats(l.formatList)
+}
+
+// 刷新列表中实现了 Flusher 的格式化接口
+// @return 第一个刷新错误
+func flushFormats(formats []Format) error {
+	var err error
+	for i := 0; i < len(formats); i++ {
+		if f, ok := formats[i].(Flusher); ok {
+			if e := f.Flush(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+
+	return err
+}
+
+// 输出日志
+// @description 格式化字符串中的占位符依次消耗参数, 剩余的参数作为键值对字段,
+// 例如 Info("user %s login", name, "ip", ip) 的消息为 "user xxx login", 字段为 ip
 func (l *Logger) Log(level Level, skipCaller int, format string, args ...interface{}) {
-	g := l.logPool.Get().(*Log)
+	args, fieldArgs := splitArgs(format, args)
+	l.log(level, skipCaller+1, format, args, fieldArgs)
+}
+
+func (l *Logger) log(level Level, skipCaller int, format string, args, fieldArgs []interface{}) {
+	if !l.admit(level, skipCaller, format) {
+		return
+	}
+
+	l.Writer(l.newLog(level, skipCaller+1, format, args, fieldArgs))
+}
+
+// 错误日志, 附带结构化调用栈和参数中错误的错误链
+func (l *Logger) logError(level Level, skipCaller int, format string, args []interface{}) {
+	if !l.admit(level, skipCaller, format) {
+		return
+	}
+
+	args, fieldArgs := splitArgs(format, args)
+	g := l.newLog(level, skipCaller+1, format, args, fieldArgs)
+	g.Stack = appendFrames(g.Stack, maxStackDepth, skipCaller)
+	g.Causes = argsCauses(g.Causes, args, g.Fields)
+
+	l.Writer(g)
+}
+
+// 模块等级和采样检查, 在格式化之前调用
+// @param skipCaller 相对于调用者的追溯层级, 与调用者中的 runtime.Caller 相同
+func (l *Logger) admit(level Level, skipCaller int, format string) bool {
+	if !l.Enabled(level) {
+		return false
+	}
+
+	if s := l.root().sampler.Load(); s != nil && level&s.opts.Levels != 0 {
+		return s.allow(l, level, skipCaller+1, format)
+	}
+
+	return true
+}
+
+func (l *Logger) newLog(level Level, skipCaller int, format string, args, fieldArgs []interface{}) *Log {
+	g := l.root().logPool.Get().(*Log)
 	g.Level = level
 	g.Message = fmt.Sprintf(format, args...)
 	_, g.File, g.Line, _ = runtime.Caller(skipCaller)
 	g.Time = time.Now()
+	g.Fields = argsToFields(append(g.Fields[:0], l.fields...), fieldArgs)
+	g.Name = l.name
 
-	l.Writer(g)
+	return g
 }
 
 func (l *Logger) Printf(format string, args ...interface{}) {
@@ -121,11 +356,13 @@ func (l *Logger) Warn(format string, args ...interface{}) {
 }
 
 func (l *Logger) Error(format string, args ...interface{}) {
-	l.Log(LevelError, defaultCaller, format+"\r\nError Stack:\r\n%s",
-		append(args, Stack(1000, 1))...)
+	l.logError(LevelError, defaultCaller, format, args)
 }
 
 func (l *Logger) Fatal(format string, args ...interface{}) {
-	l.Log(LevelFatal, defaultCaller, format, args...)
+	l.logError(LevelFatal, defaultCaller, format, args)
+	l.Flush()
+
+	args, _ = splitArgs(format, args)
 	panic(fmt.Sprintf(format, args...))
 }
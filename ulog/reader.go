@@ -0,0 +1,617 @@
+package ulog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 日志文件中的一条记录
+// @description PureFormat 和 PrettyFormat 的字段无法与消息区分, 保留在 Message 中;
+// 多行记录中的错误链和调用栈会被解析到 Causes 和 Stack
+type Record struct {
+	Log
+	Source string // 所在的文件名
+	Raw    string // 原始文本, 已清除 ANSI 颜色, 多行记录包含所有行
+
+	section   uint8  // 多行记录当前所在的段落
+	causeText string // 跨行的错误消息
+}
+
+const (
+	sectionMessage uint8 = iota
+	sectionCauses
+	sectionStack
+)
+
+type ReaderOptions struct {
+	FileFormat   string         // RotateWriter 的文件名时间格式, 为空时读取目录中所有文件并按修改时间排序
+	Location     *time.Location // PureFormat 时间的时区, 默认 time.Local
+	Levels       Level          // 等级过滤, 默认所有等级, LevelFatal 总是输出, 没有开头的行等级为零值会被过滤
+	Since        time.Time      // 只输出此时间之后 (包含) 的记录, 没有开头的行时间为零值会被过滤
+	Until        time.Time      // 只输出此时间之前的记录
+	Caller       string         // 追溯位置过滤, 例如 "db.go" 或 "db.go:42"
+	Match        *regexp.Regexp // 匹配消息和字段
+	Follow       bool           // 读到末尾后等待新的日志, 跟随轮转, 最后一条记录在下一条记录开始, 切换文件或关闭时才输出
+	PollInterval time.Duration  // 跟随模式检查新日志的间隔, 默认 200ms
+}
+
+// 日志目录读取器, 按时间顺序读取 RotateWriter 写入的当前文件和轮转文件 (包括 .gz)
+// @description 跟随模式下, 当前文件被重命名 (按大小轮转或外部 logrotate) 后读完旧文件再打开新文件,
+// 设置了 FileFormat 时还会切换到之后按时间轮转出的新文件
+type Reader struct {
+	dir        string
+	opts       ReaderOptions
+	callerFile string
+	callerLine int
+	files      []logFile // 待读取的文件, 按时间顺序
+	cur        logFile
+	f          *os.File
+	br         *bufio.Reader
+	partial    string  // 跟随模式下未写完的行
+	pending    *Record // 等待后续行的多行记录
+	lock       sync.Mutex
+	closed     chan struct{}
+	once       sync.Once
+}
+
+type logFile struct {
+	name    string
+	base    time.Time // 文件名中的时间
+	index   int       // 按大小轮转的序号, 当前文件为 0
+	modTime time.Time
+}
+
+var (
+	pureHeaderRegexp = regexp.MustCompile(`^(PRINTF|TRACE|DEBUG|INFO|WARN|ERROR|FATAL|UNKNOWN) (\d{2}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{3}) (\S*):(\d+) (.*)$`)
+	causeLineRegexp  = regexp.MustCompile(`^(?s)(.*) \(([^()\s]+)\)$`)
+	numberedRegexp   = regexp.MustCompile(`^(\d+)\.`)
+)
+
+// 创建日志目录读取器
+// @param dir 日志目录
+// @param opts 选项
+func NewReader(dir string, opts ReaderOptions) (*Reader, error) {
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 200 * time.Millisecond
+	}
+
+	r := &Reader{dir: dir, opts: opts, closed: make(chan struct{})}
+
+	if opts.Caller != "" {
+		r.callerFile = opts.Caller
+		if i := strings.LastIndexByte(opts.Caller, ':'); i > -1 {
+			line, e := strconv.Atoi(opts.Caller[i+1:])
+			if e != nil {
+				return nil, errors.New("invalid caller: " + opts.Caller)
+			}
+
+			r.callerFile, r.callerLine = opts.Caller[:i], line
+		}
+	}
+
+	files, e := r.list()
+	if e != nil {
+		return nil, e
+	}
+
+	r.files = files
+	return r, nil
+}
+
+// 读取下一条符合过滤条件的记录
+// @return 没有更多记录或读取器已关闭时返回 io.EOF, 跟随模式下会等待新的日志
+func (r *Reader) Next() (*Record, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for {
+		line, e := r.readLine()
+		if e == nil {
+			if rec := r.appendLine(line); rec != nil && r.match(rec) {
+				return rec, nil
+			}
+			continue
+		}
+
+		if e != io.EOF {
+			return nil, e
+		}
+
+		// 文件结束时多行记录已经完整
+		if !r.opts.Follow {
+			if rec := r.flush(); rec != nil {
+				return rec, nil
+			}
+		}
+
+		// 跟随模式下后续行可能还没有写入, 只在切换文件或关闭时输出
+		f, e := r.f, r.advance()
+		if r.opts.Follow && (e != nil || r.f != f) {
+			if rec := r.flush(); rec != nil {
+				return rec, nil
+			}
+		}
+
+		if e != nil {
+			return nil, e
+		}
+	}
+}
+
+// 取出等待后续行的记录, 不符合过滤条件时返回 nil
+func (r *Reader) flush() *Record {
+	rec := r.pending
+	r.pending = nil
+	if rec != nil && r.match(rec) {
+		return rec
+	}
+
+	return nil
+}
+
+// 关闭读取器, 可以在其它协程中调用以结束跟随模式下的 Next
+func (r *Reader) Close() error {
+	r.once.Do(func() {
+		close(r.closed)
+	})
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.closeFile()
+}
+
+func (r *Reader) closeFile() error {
+	if r.f == nil {
+		return nil
+	}
+
+	e := r.f.Close()
+	r.f, r.br, r.partial = nil, nil, ""
+	return e
+}
+
+// 目录中的日志文件, 按时间顺序排序, 跳过目录, 符号链接和压缩中的临时文件
+func (r *Reader) list() ([]logFile, error) {
+	entries, e := os.ReadDir(r.dir)
+	if e != nil {
+		return nil, e
+	}
+
+	list := []logFile{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+
+		lf := logFile{name: name}
+		if r.opts.FileFormat != "" {
+			base, index, ok := parseRotateName(r.opts.FileFormat, name)
+			if !ok {
+				continue
+			}
+
+			lf.base, lf.index = base, index
+		}
+
+		if fi, e := entry.Info(); e == nil {
+			lf.modTime = fi.ModTime()
+		}
+
+		list = append(list, lf)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].before(list[j])
+	})
+
+	return list, nil
+}
+
+// 同一时间的文件中, 轮转文件按修改时间排序, 当前文件在最后;
+// 旧版本 RotateWriter 会在清理后复用轮转序号, 不能只按序号排序
+func (lf logFile) before(other logFile) bool {
+	if !lf.base.Equal(other.base) {
+		return lf.base.Before(other.base)
+	}
+
+	if (lf.index == 0) != (other.index == 0) {
+		return other.index == 0
+	}
+
+	if !lf.modTime.Equal(other.modTime) {
+		return lf.modTime.Before(other.modTime)
+	}
+
+	if lf.index != other.index {
+		return lf.index < other.index
+	}
+
+	return lf.name < other.name
+}
+
+// 读取一行, 不包含换行符
+func (r *Reader) readLine() (string, error) {
+	if r.br == nil {
+		return "", io.EOF
+	}
+
+	line, e := r.br.ReadString('\n')
+	if e == io.EOF && line != "" && r.opts.Follow && !strings.HasSuffix(r.cur.name, ".gz") {
+		r.partial += line
+		return "", io.EOF
+	} else if e != nil && (e != io.EOF || line == "") {
+		return "", e
+	}
+
+	line, r.partial = r.partial+line, ""
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// 处理一行, 新的记录开始时返回上一条完整的记录
+func (r *Reader) appendLine(line string) *Record {
+	line = CleanANSI(line)
+
+	rec, ok := ParseRecord(line, r.opts.Location)
+	if !ok {
+		if r.pending != nil {
+			r.pending.appendLine(line)
+			return nil
+		}
+
+		// 没有开头的行单独作为一条记录, 时间和等级为零值, 设置了 Levels, Since 或 Until 时会被过滤
+		rec = &Record{Raw: line}
+		rec.Message = line
+	}
+
+	rec.Source = r.cur.name
+	prev := r.pending
+	r.pending = rec
+
+	return prev
+}
+
+// 打开下一个文件, 跟随模式下等待新的日志
+func (r *Reader) advance() error {
+	for {
+		if len(r.files) > 0 {
+			r.closeFile()
+			return r.open(r.files[0])
+		}
+
+		if !r.opts.Follow {
+			r.closeFile()
+			return io.EOF
+		}
+
+		if ok, e := r.poll(); e != nil || ok {
+			return e
+		}
+
+		r.lock.Unlock()
+		t := time.NewTimer(r.opts.PollInterval)
+
+		select {
+		case <-r.closed:
+			t.Stop()
+			r.lock.Lock()
+			return io.EOF
+		case <-t.C:
+		}
+
+		r.lock.Lock()
+	}
+}
+
+// 检查当前文件是否有新的内容, 是否被轮转, 以及是否有新的文件
+// @return 是否可以继续读取
+func (r *Reader) poll() (bool, error) {
+	select {
+	case <-r.closed:
+		return false, io.EOF
+	default:
+	}
+
+	// 还没有打开过文件, 或者上一个文件在打开之前被删除
+	if r.f == nil {
+		files, e := r.list()
+		if e != nil {
+			return false, e
+		}
+
+		for _, lf := range files {
+			if r.cur.name == "" || r.cur.before(lf) {
+				r.files = append(r.files, lf)
+			}
+		}
+
+		return len(r.files) > 0, nil
+	}
+
+	fi, e := r.f.Stat()
+	if e != nil {
+		return false, e
+	}
+
+	offset, e := r.f.Seek(0, io.SeekCurrent)
+	if e != nil {
+		return false, e
+	}
+
+	if fi.Size() > offset {
+		return true, nil
+	} else if fi.Size() < offset {
+		// 文件被截断, 从头开始读
+		r.partial = ""
+		_, e := r.f.Seek(0, io.SeekStart)
+		r.br.Reset(r.f)
+		return e == nil, e
+	}
+
+	// 当前文件被重命名后打开同名的新文件
+	if nfi, e := os.Stat(path.Join(r.dir, r.cur.name)); e == nil && !os.SameFile(fi, nfi) {
+		r.files = []logFile{r.cur}
+		return true, nil
+	}
+
+	if r.opts.FileFormat == "" {
+		return false, nil
+	}
+
+	files, e := r.list()
+	if e != nil {
+		return false, e
+	}
+
+	// 只有没有序号的文件会继续写入, 轮转文件都已经读过
+	for _, lf := range files {
+		if lf.index == 0 && !strings.HasSuffix(lf.name, ".gz") && r.cur.before(lf) && lf.name != r.cur.name {
+			r.files = append(r.files, lf)
+		}
+	}
+
+	return len(r.files) > 0, nil
+}
+
+func (r *Reader) open(lf logFile) error {
+	r.files = r.files[1:]
+
+	f, e := os.Open(path.Join(r.dir, lf.name))
+	if os.IsNotExist(e) && !strings.HasSuffix(lf.name, ".gz") {
+		// 读取之前已经被压缩
+		lf.name += ".gz"
+		f, e = os.Open(path.Join(r.dir, lf.name))
+	}
+
+	if os.IsNotExist(e) {
+		return nil
+	} else if e != nil {
+		return e
+	}
+
+	r.f, r.cur = f, lf
+	if !strings.HasSuffix(lf.name, ".gz") {
+		r.br = bufio.NewReader(f)
+		return nil
+	}
+
+	gz, e := gzip.NewReader(f)
+	if e != nil {
+		r.closeFile()
+		return errors.New(lf.name + ": " + e.Error())
+	}
+
+	r.br = bufio.NewReader(gz)
+	return nil
+}
+
+func (r *Reader) match(rec *Record) bool {
+	opts := &r.opts
+
+	if opts.Levels != LevelMuted && opts.Levels&rec.Level == 0 && rec.Level != LevelFatal {
+		return false
+	}
+
+	if (!opts.Since.IsZero() && rec.Time.Before(opts.Since)) ||
+		(!opts.Until.IsZero() && !rec.Time.Before(opts.Until)) {
+		return false
+	}
+
+	if r.callerFile != "" && (path.Base(rec.File) != r.callerFile || (r.callerLine > 0 && rec.Line != r.callerLine)) {
+		return false
+	}
+
+	if opts.Match != nil && !opts.Match.MatchString(rec.Message+formatFields(rec.Fields, func(k string) string { return k })) {
+		return false
+	}
+
+	return true
+}
+
+// 解析一行日志的开头, 支持 PureFormat, 清除颜色后的 PrettyFormat 和 JSONFormat
+// @param line 一行日志, 不包含换行符
+// @param loc PureFormat 时间的时区
+// @return 不是日志开头时返回 false
+func ParseRecord(line string, loc *time.Location) (*Record, bool) {
+	if strings.HasPrefix(line, "{") {
+		return parseJSONRecord(line)
+	}
+
+	m := pureHeaderRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+
+	rec := &Record{Raw: line}
+	rec.Level, _ = ParseLevel(m[1])
+	rec.Time, _ = time.ParseInLocation("06-01-02 15:04:05.000", m[2], loc)
+	rec.File = m[3]
+	rec.Line, _ = strconv.Atoi(m[4])
+	rec.Message = m[5]
+
+	// 模块名称前缀, 例如 "[upg.pool] "
+	if strings.HasPrefix(rec.Message, "[") {
+		if i := strings.Index(rec.Message, "] "); i > 1 && !strings.ContainsAny(rec.Message[1:i], " []") {
+			rec.Name, rec.Message = rec.Message[1:i], rec.Message[i+2:]
+		}
+	}
+
+	return rec, true
+}
+
+// 多行记录的后续行
+func (rec *Record) appendLine(line string) {
+	rec.Raw += "\n" + line
+
+	switch {
+	case line == "Caused by:":
+		rec.section = sectionCauses
+		return
+	case line == "Error Stack:":
+		rec.flushCause()
+		rec.section = sectionStack
+		return
+	}
+
+	switch rec.section {
+	case sectionCauses:
+		text := line
+		if rec.causeText != "" {
+			text = rec.causeText + "\n" + line
+		} else if m := numberedRegexp.FindString(line); m != "" {
+			text = strings.TrimPrefix(line[len(m):], " ")
+		}
+
+		rec.causeText = text
+		if m := causeLineRegexp.FindStringSubmatch(text); m != nil {
+			rec.Causes = append(rec.Causes, Cause{Type: m[2], Message: m[1]})
+			rec.causeText = ""
+		}
+	case sectionStack:
+		if strings.HasPrefix(line, "\t") && len(rec.Stack) > 0 {
+			f := &rec.Stack[len(rec.Stack)-1]
+			location := line[1:]
+			if i := strings.LastIndexByte(location, ':'); i > -1 {
+				f.File = location[:i]
+				f.Line, _ = strconv.Atoi(location[i+1:])
+			}
+		} else if m := numberedRegexp.FindString(line); m != "" {
+			rec.Stack = append(rec.Stack, Frame{Function: line[len(m):]})
+		}
+	default:
+		rec.Message += "\n" + line
+	}
+}
+
+// 没有类型后缀的错误消息
+func (rec *Record) flushCause() {
+	if rec.causeText != "" {
+		rec.Causes = append(rec.Causes, Cause{Message: rec.causeText})
+		rec.causeText = ""
+	}
+}
+
+// 解析 JSONFormat 的一行, 未知的键作为字段, 与固定键重名的 fields. 前缀会被去掉
+func parseJSONRecord(line string) (*Record, bool) {
+	d := json.NewDecoder(strings.NewReader(line))
+	d.UseNumber()
+
+	if t, e := d.Token(); e != nil || t != json.Delim('{') {
+		return nil, false
+	}
+
+	rec := &Record{Raw: line}
+	hasLevel := false
+	for d.More() {
+		t, e := d.Token()
+		if e != nil {
+			return nil, false
+		}
+
+		key, _ := t.(string)
+		raw := json.RawMessage{}
+		if e := d.Decode(&raw); e != nil {
+			return nil, false
+		}
+
+		switch key {
+		case "time":
+			s := ""
+			json.Unmarshal(raw, &s)
+			rec.Time, _ = time.Parse(time.RFC3339Nano, s)
+		case "level":
+			s := ""
+			json.Unmarshal(raw, &s)
+			rec.Level, e = ParseLevel(s)
+			hasLevel = e == nil
+		case "caller":
+			s := ""
+			json.Unmarshal(raw, &s)
+			if i := strings.LastIndexByte(s, ':'); i > -1 {
+				rec.File = s[:i]
+				rec.Line, _ = strconv.Atoi(s[i+1:])
+			}
+		case "logger":
+			json.Unmarshal(raw, &rec.Name)
+		case "msg":
+			json.Unmarshal(raw, &rec.Message)
+		case "stack":
+			if json.Unmarshal(raw, &rec.Stack) != nil {
+				s := ""
+				json.Unmarshal(raw, &s)
+				rec.Stack = parseInlineStack(s)
+			}
+		case "causes":
+			json.Unmarshal(raw, &rec.Causes)
+		default:
+			if name, ok := strings.CutPrefix(key, "fields."); ok && jsonReservedKeys[name] {
+				key = name
+			}
+
+			var v interface{}
+			vd := json.NewDecoder(strings.NewReader(string(raw)))
+			vd.UseNumber()
+			vd.Decode(&v)
+			rec.Fields = append(rec.Fields, Field{key, v})
+		}
+	}
+
+	return rec, hasLevel
+}
+
+// 解析 StackInline 模式的 JSON 调用栈, 每帧为 "函数\n\t文件:行号"
+func parseInlineStack(s string) []Frame {
+	frames := []Frame{}
+	for _, line := range strings.Split(s, "\n") {
+		if !strings.HasPrefix(line, "\t") {
+			frames = append(frames, Frame{Function: line})
+			continue
+		}
+
+		if len(frames) > 0 {
+			f := &frames[len(frames)-1]
+			if i := strings.LastIndexByte(line, ':'); i > 0 {
+				f.File = line[1:i]
+				f.Line, _ = strconv.Atoi(line[i+1:])
+			}
+		}
+	}
+
+	return frames
+}
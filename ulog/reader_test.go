@@ -0,0 +1,211 @@
+package ulog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeLogFile(t *testing.T, name, data string, modTime time.Time) {
+	if strings.HasSuffix(name, ".gz") {
+		b := &bytes.Buffer{}
+		if e := writeGzip(b, strings.NewReader(data)); e != nil {
+			t.Fatal(e)
+		}
+		data = b.String()
+	}
+
+	if e := os.WriteFile(name, []byte(data), 0o644); e != nil {
+		t.Fatal(e)
+	}
+
+	os.Chtimes(name, modTime, modTime)
+}
+
+func readAll(t *testing.T, r *Reader) []*Record {
+	list := []*Record{}
+	for {
+		rec, e := r.Next()
+		if e == io.EOF {
+			return list
+		} else if e != nil {
+			t.Fatal(e)
+		}
+
+		list = append(list, rec)
+	}
+}
+
+func TestReader(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	// 旧版本 RotateWriter 会在序号 1 被清理后复用它, 这时序号 1 比序号 2 新
+	writeLogFile(t, path.Join(dir, "2024-01-01.log.2.gz"),
+		"INFO 24-01-01 10:00:00.000 main.go:10 first\r\n", now.Add(-3*time.Hour))
+	writeLogFile(t, path.Join(dir, "2024-01-01.log.1"),
+		"\x1b[1mWARN\x1b[0m 24-01-01 11:00:00.000 db.go:42 [upg.pool] slow query cost=2s\r\n", now.Add(-2*time.Hour))
+	writeLogFile(t, path.Join(dir, "2024-01-01.log"),
+		`{"time":"2024-01-01T12:00:00Z","level":"ERROR","caller":"db.go:50","logger":"upg","msg":"query failed","fields.msg":"x","rows":3,"stack":[{"func":"main.run","file":"/app/main.go","line":7}],"causes":[{"type":"*errors.errorString","msg":"timeout"}]}`+"\n",
+		now.Add(-time.Hour))
+	writeLogFile(t, path.Join(dir, "2024-01-02.log"),
+		"ERROR 24-01-02 09:00:00.000 app.go:5 boot failed: a\r\n"+
+			"b\r\nCaused by:\r\n1. a\nb (*errors.joinError)\r\n2. a (*errors.errorString)\r\n"+
+			"Error Stack:\r\n1.main.main\r\n\t/app/main.go:20\r\n"+
+			"DEBUG 24-01-02 09:00:01.000 app.go:6 done\r\n", now)
+	writeLogFile(t, path.Join(dir, "notes.txt"), "not a log\n", now)
+	os.Symlink("2024-01-02.log", path.Join(dir, "current.log"))
+
+	r, e := NewReader(dir, ReaderOptions{FileFormat: "2006-01-02.log", Location: time.UTC})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	list := readAll(t, r)
+	messages := []string{}
+	for _, rec := range list {
+		messages = append(messages, rec.Message)
+	}
+
+	if strings.Join(messages, "|") != "first|slow query cost=2s|query failed|boot failed: a\nb|done" {
+		t.Fatalf("messages: %q", messages)
+	}
+
+	if g := list[1]; g.Level != LevelWarn || g.Name != "upg.pool" || g.File != "db.go" || g.Line != 42 ||
+		g.Source != "2024-01-01.log.1" || !g.Time.Equal(time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Fatalf("pure: %+v", g)
+	}
+
+	if g := list[2]; g.Name != "upg" || len(g.Fields) != 2 || g.Fields[0].Key != "msg" ||
+		len(g.Stack) != 1 || g.Stack[0].Line != 7 || len(g.Causes) != 1 || g.Causes[0].Message != "timeout" {
+		t.Fatalf("json: %+v", g)
+	}
+
+	if g := list[3]; len(g.Causes) != 2 || g.Causes[0].Message != "a\nb" || g.Causes[1].Type != "*errors.errorString" ||
+		len(g.Stack) != 1 || g.Stack[0] != (Frame{Function: "main.main", File: "/app/main.go", Line: 20}) {
+		t.Fatalf("multiline: %+v", g)
+	}
+
+	r, _ = NewReader(dir, ReaderOptions{
+		FileFormat: "2006-01-02.log",
+		Location:   time.UTC,
+		Levels:     LevelWarn | LevelError,
+		Since:      time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC),
+		Caller:     "db.go",
+		Match:      regexp.MustCompile(`rows=3`),
+	})
+
+	if list := readAll(t, r); len(list) != 1 || list[0].Message != "query failed" {
+		t.Fatalf("filter: %+v", list)
+	}
+}
+
+func TestReaderFollow(t *testing.T) {
+	dir := t.TempDir()
+	current := path.Join(dir, "2024-01-01.log")
+	writeLogFile(t, current, "INFO 24-01-01 10:00:00.000 main.go:1 one\r\n", time.Now())
+
+	r, e := NewReader(dir, ReaderOptions{FileFormat: "2006-01-02.log", Follow: true, PollInterval: 5 * time.Millisecond})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	records := make(chan *Record, 8)
+	done := make(chan error, 1)
+	go func() {
+		for {
+			rec, e := r.Next()
+			if e != nil {
+				done <- e
+				return
+			}
+			records <- rec
+		}
+	}()
+
+	expect := func(message string) *Record {
+		select {
+		case rec := <-records:
+			if rec.Message != message {
+				t.Fatalf("message: %q != %q", rec.Message, message)
+			}
+			return rec
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %q", message)
+		}
+		return nil
+	}
+
+	appendLog := func(name, data string) {
+		f, e := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if e != nil {
+			t.Fatal(e)
+		}
+		f.WriteString(data)
+		f.Close()
+	}
+
+	// 读到文件末尾后才写入的后续行属于同一条记录, 下一条记录开始后才输出
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case rec := <-records:
+		t.Fatalf("flushed before continuation: %+v", rec)
+	default:
+	}
+
+	appendLog(current, "Error Stack:\r\n1.main.main\r\n\tmain.go:1\r\n")
+	appendLog(current, "INFO 24-01-01 10:00:01.000 main.go:2 two\r\n")
+	if rec := expect("one"); len(rec.Stack) != 1 || rec.Stack[0].File != "main.go" {
+		t.Fatalf("stack: %+v", rec.Stack)
+	}
+
+	// 按大小轮转: 旧文件写入后被重命名并压缩, 新文件使用相同的名称, 切换文件时输出上一条记录
+	os.Rename(current, current+".1")
+	appendLog(current, "INFO 24-01-01 10:00:02.000 main.go:3 three\r\n")
+	expect("two")
+
+	rw := &RotateWriter{root: dir, opts: RotateOptions{Compress: true}, fileFormat: "2006-01-02.log", currentName: "2024-01-01.log"}
+	rw.compressAndClean()
+
+	// 按时间轮转
+	appendLog(path.Join(dir, "2024-01-02.log"), "INFO 24-01-02 00:00:00.000 main.go:4 four\r\n")
+	expect("three")
+
+	// 关闭时输出最后一条记录
+	r.Close()
+	expect("four")
+	if e := <-done; !errors.Is(e, io.EOF) {
+		t.Fatal(e)
+	}
+
+	if len(records) != 0 {
+		t.Fatalf("duplicate: %+v", <-records)
+	}
+
+	if _, e := os.Stat(current + ".1.gz"); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	df := NewDefaultFormat(nil)
+	g := &Log{Level: LevelInfo, Time: time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local), File: "/x/a.go", Line: 3, Name: "web", Message: "hello"}
+
+	for _, line := range []string{df.PrettyFormat(g), df.PureFormat(g), NewJSONFormat(nil).Format(g)} {
+		rec, ok := ParseRecord(CleanANSI(strings.TrimRight(line, "\r\n")), time.Local)
+		if !ok || rec.Level != LevelInfo || rec.Name != "web" || rec.Message != "hello" || rec.File != "a.go" ||
+			rec.Line != 3 || !rec.Time.Equal(g.Time) {
+			t.Fatalf("%q: %+v", line, rec)
+		}
+	}
+
+	if _, ok := ParseRecord("\tat main.go:1", time.Local); ok {
+		t.Fatal("continuation line parsed as record")
+	}
+}
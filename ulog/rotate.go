@@ -6,36 +6,69 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
+var ErrRotateClosed = errors.New("rotate writer is closed")
+
+type RotateOptions struct {
+	IntervalDay    int           // 按天轮转的间隔, 文件名由 fileFormat 格式化当前时间得到
+	MaxSize        int64         // 单个文件的最大字节数, 超过后轮转为 <文件名>.<序号>, 0 不限制
+	MaxBackups     int           // 保留的轮转文件数量, 0 不限制
+	MaxAge         time.Duration // 轮转文件按修改时间保留的时长, 0 不限制
+	Compress       bool          // 在后台压缩轮转文件为 .gz
+	Symlink        string        // 指向当前文件的符号链接名称, 位于日志目录中, 为空不创建
+	ReopenOnSIGHUP bool          // 收到 SIGHUP 时重新打开当前文件, 兼容外部 logrotate
+}
+
 type RotateWriter struct {
 	root        string
 	fileFormat  string
+	opts        RotateOptions
 	currentName string
-	current     io.WriteCloser
-	intervalDay int
-	compress    bool
+	current     io.WriteCloser // 打开失败时为空, 下一次写入时重新打开
+	closed      bool
+	size        atomic.Int64 // 当前文件大小
 	rw          sync.RWMutex
+	maintain    chan struct{} // 触发后台压缩和清理
 	exited      chan struct{}
+	wg          sync.WaitGroup
 }
 
 func NewRotateWriter(dir, fileFormat string, intervalDay int, compress ...bool) (*RotateWriter, error) {
-	if intervalDay < 1 {
-		return nil, fmt.Errorf("intervalDay must be >= 1")
+	opts := RotateOptions{IntervalDay: intervalDay}
+	if len(compress) > 0 {
+		opts.Compress = compress[0]
 	}
 
-	l := &RotateWriter{
-		root:        dir,
-		fileFormat:  fileFormat,
-		intervalDay: intervalDay,
-		exited:      make(chan struct{}),
+	return NewRotateWriterOptions(dir, fileFormat, opts)
+}
+
+// 创建轮转写入器
+// @description 按时间和大小轮转, 轮转后的文件在后台压缩并按 MaxBackups/MaxAge 清理,
+// 清理只处理文件名 (去掉 .gz 和 .<序号> 后) 能被 fileFormat 解析的文件
+// @param dir 日志目录
+// @param fileFormat 文件名的时间格式, 例如 "2006-01-02.log"
+// @param opts 选项
+func NewRotateWriterOptions(dir, fileFormat string, opts RotateOptions) (*RotateWriter, error) {
+	if opts.IntervalDay < 1 {
+		return nil, fmt.Errorf("intervalDay must be >= 1")
 	}
 
-	if len(compress) > 0 {
-		l.compress = compress[0]
+	l := &RotateWriter{
+		root:       dir,
+		fileFormat: fileFormat,
+		opts:       opts,
+		maintain:   make(chan struct{}, 1),
+		exited:     make(chan struct{}),
 	}
 
 	if e := l.setup(); e != nil {
@@ -47,31 +80,51 @@ func NewRotateWriter(dir, fileFormat string, intervalDay int, compress ...bool)
 
 func (r *RotateWriter) Write(p []byte) (int, error) {
 	r.rw.RLock()
-	defer r.rw.RUnlock()
+	for r.current == nil {
+		r.rw.RUnlock()
+		if e := r.reopen(); e != nil {
+			return 0, e
+		}
+		r.rw.RLock()
+	}
+
+	n, e := r.current.Write(p)
+	size := r.size.Add(int64(n))
+	r.rw.RUnlock()
 
-	if r.current == nil {
-		return 0, errors.New("rotate writer is closed")
+	if r.opts.MaxSize > 0 && size >= r.opts.MaxSize {
+		if e := r.rotateSize(); e != nil {
+			os.Stderr.WriteString("log rotate: " + e.Error() + "\n")
+		}
 	}
 
-	return r.current.Write(p)
+	return n, e
 }
 
 func (r *RotateWriter) WriteString(s string) (int, error) {
 	return r.Write([]byte(s))
 }
 
+// 关闭当前文件并停止后台协程, 当前文件打开失败时也会停止后台协程
 func (r *RotateWriter) Close() error {
 	r.rw.Lock()
-	defer r.rw.Unlock()
+	if r.closed {
+		r.rw.Unlock()
+		return ErrRotateClosed
+	}
 
-	if err := r.current.Close(); err != nil {
-		return err
+	var e error
+	if r.current != nil {
+		e = r.current.Close()
+		r.current = nil
 	}
-	r.current = nil
-	r.exited <- struct{}{}
-	defer close(r.exited)
+	r.closed = true
+	r.rw.Unlock()
 
-	return nil
+	close(r.exited)
+	r.wg.Wait()
+
+	return e
 }
 
 func (r *RotateWriter) setup() error {
@@ -86,7 +139,14 @@ func (r *RotateWriter) setup() error {
 		return errors.New("root must be a directory")
 	}
 
+	if e := r.openCurrent(); e != nil {
+		return e
+	}
+
+	r.wg.Add(2)
 	go func(r *RotateWriter) {
+		defer r.wg.Done()
+
 		t := time.NewTimer(r.getNextIntervalDuration())
 		defer t.Stop()
 
@@ -106,13 +166,50 @@ func (r *RotateWriter) setup() error {
 		}
 	}(r)
 
-	return r.openCurrent()
+	go func(r *RotateWriter) {
+		defer r.wg.Done()
+
+		for {
+			select {
+			case <-r.exited:
+				return
+			case <-r.maintain:
+				r.compressAndClean()
+			}
+		}
+	}(r)
+
+	if r.opts.ReopenOnSIGHUP {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGHUP)
+
+		r.wg.Add(1)
+		go func(r *RotateWriter) {
+			defer r.wg.Done()
+			defer signal.Stop(sig)
+
+			for {
+				select {
+				case <-r.exited:
+					return
+				case <-sig:
+					if e := r.Reopen(); e != nil {
+						os.Stderr.WriteString("log reopen: " + e.Error() + "\n")
+					}
+				}
+			}
+		}(r)
+	}
+
+	// 处理上次运行遗留的轮转文件
+	r.triggerMaintain()
+	return nil
 }
 
 func (r *RotateWriter) getNextIntervalDuration() time.Duration {
 	nt := time.Now()
 	nt = time.Date(nt.Year(), nt.Month(), nt.Day(), 0, 0, 0, 0, nt.Location())
-	nt = nt.AddDate(0, 0, r.intervalDay)
+	nt = nt.AddDate(0, 0, r.opts.IntervalDay)
 
 	return time.Until(nt)
 }
@@ -121,65 +218,321 @@ func (r *RotateWriter) openCurrent() (e error) {
 	r.rw.Lock()
 	defer r.rw.Unlock()
 
+	if r.closed {
+		return ErrRotateClosed
+	}
+
 	nextName := time.Now().Format(r.fileFormat)
-	if nextName == r.currentName {
+	if nextName == r.currentName && r.current != nil {
 		return nil
 	}
 
+	var ce error
 	if r.current != nil {
-		if e := r.current.Close(); e != nil {
-			return e
+		ce = r.closeCurrent()
+		r.triggerMaintain()
+	}
+
+	r.currentName = nextName
+	return errors.Join(ce, r.open())
+}
+
+func (r *RotateWriter) OpenCurrent() error {
+	return r.openCurrent()
+}
+
+// 重新打开当前文件, 用于外部程序移动了当前文件之后
+func (r *RotateWriter) Reopen() error {
+	r.rw.Lock()
+	defer r.rw.Unlock()
+
+	if r.closed {
+		return ErrRotateClosed
+	}
+
+	var ce error
+	if r.current != nil {
+		ce = r.closeCurrent()
+	}
+
+	return errors.Join(ce, r.open())
+}
+
+// 当前文件打开失败后重新打开, 例如磁盘恢复后
+func (r *RotateWriter) reopen() error {
+	r.rw.Lock()
+	defer r.rw.Unlock()
+
+	if r.closed {
+		return ErrRotateClosed
+	}
+
+	if r.current != nil {
+		return nil
+	}
+
+	return r.open()
+}
+
+// 关闭当前文件, 关闭失败时也丢弃文件句柄, 需要持有写锁
+func (r *RotateWriter) closeCurrent() error {
+	e := r.current.Close()
+	r.current = nil
+	return e
+}
+
+// 当前文件超过最大大小时重命名为 <文件名>.<序号>, 然后重新打开
+// @description 序号为已有的最大序号加一, 旧的序号被清理后也不会复用, 序号越大越新
+func (r *RotateWriter) rotateSize() error {
+	r.rw.Lock()
+	defer r.rw.Unlock()
+
+	// 其它写入已经完成了轮转
+	if r.closed || r.current == nil || r.size.Load() < r.opts.MaxSize {
+		return nil
+	}
+
+	ce := r.closeCurrent()
+
+	name := path.Join(r.root, r.currentName)
+	for i := r.maxIndex() + 1; ; i++ {
+		backup := name + "." + strconv.Itoa(i)
+		if fileExists(backup) || fileExists(backup+".gz") {
+			continue
 		}
 
-		if r.currentName != "" && r.compress {
-			if e := r.compressCurrent(); e != nil {
-				os.Stderr.WriteString("log rotate: " + e.Error() + "\n")
-			} else {
-				os.Remove(path.Join(r.root, r.currentName))
+		if e := os.Rename(name, backup); e != nil {
+			os.Stderr.WriteString("log rotate: " + e.Error() + "\n")
+		}
+		break
+	}
+
+	r.triggerMaintain()
+	return errors.Join(ce, r.open())
+}
+
+// 当前文件已有的最大轮转序号, 包括压缩的文件, 需要持有锁
+func (r *RotateWriter) maxIndex() int {
+	entries, e := os.ReadDir(r.root)
+	if e != nil {
+		return 0
+	}
+
+	index := 0
+	for _, entry := range entries {
+		if s, ok := strings.CutPrefix(strings.TrimSuffix(entry.Name(), ".gz"), r.currentName+"."); ok {
+			if i, e := strconv.Atoi(s); e == nil && i > index {
+				index = i
 			}
 		}
 	}
 
-	r.currentName = nextName
-	r.current, e = os.OpenFile(path.Join(r.root, r.currentName),
+	return index
+}
+
+// 打开 currentName, 需要持有写锁
+func (r *RotateWriter) open() error {
+	f, e := os.OpenFile(path.Join(r.root, r.currentName),
 		os.O_RDWR|os.O_CREATE|os.O_APPEND|os.O_SYNC, os.ModePerm)
-	return
+	if e != nil {
+		r.current = nil
+		return e
+	}
+
+	r.current = f
+	r.size.Store(0)
+	if fi, e := f.Stat(); e == nil {
+		r.size.Store(fi.Size())
+	}
+
+	if r.opts.Symlink != "" {
+		if e := r.symlink(); e != nil {
+			os.Stderr.WriteString("log rotate: " + e.Error() + "\n")
+		}
+	}
+
+	return nil
 }
 
-func (r *RotateWriter) OpenCurrent() error {
-	return r.openCurrent()
+// 原子地替换符号链接
+func (r *RotateWriter) symlink() error {
+	link := path.Join(r.root, r.opts.Symlink)
+	tmp := link + ".tmp"
+
+	os.Remove(tmp)
+	if e := os.Symlink(r.currentName, tmp); e != nil {
+		return e
+	}
+
+	return os.Rename(tmp, link)
+}
+
+func (r *RotateWriter) triggerMaintain() {
+	select {
+	case r.maintain <- struct{}{}:
+	default:
+	}
+}
+
+type rotateBackup struct {
+	name    string
+	modTime time.Time
+}
+
+// 轮转文件, 不包含当前文件和符号链接, 按修改时间从新到旧排序
+func (r *RotateWriter) backups() ([]rotateBackup, error) {
+	entries, e := os.ReadDir(r.root)
+	if e != nil {
+		return nil, e
+	}
+
+	r.rw.RLock()
+	currentName := r.currentName
+	r.rw.RUnlock()
+
+	list := []rotateBackup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == currentName || name == r.opts.Symlink || !r.isBackup(name) {
+			continue
+		}
+
+		fi, e := entry.Info()
+		if e != nil {
+			continue
+		}
+
+		list = append(list, rotateBackup{name: name, modTime: fi.ModTime()})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].modTime.After(list[j].modTime)
+	})
+
+	return list, nil
+}
+
+// 文件名去掉 .gz 和 .<序号> 后是否能被 fileFormat 解析
+func (r *RotateWriter) isBackup(name string) bool {
+	_, _, ok := parseRotateName(r.fileFormat, name)
+	return ok
 }
 
-func (r *RotateWriter) compressCurrent() error {
-	f, e := os.Open(path.Join(r.root, r.currentName))
+// 解析轮转文件名
+// @return base 文件名中的时间
+// @return index 按大小轮转的序号, 当前文件 (没有序号) 为 0
+// @return ok 去掉 .gz 和 .<序号> 后能被 fileFormat 解析
+func parseRotateName(fileFormat, name string) (base time.Time, index int, ok bool) {
+	name = strings.TrimSuffix(name, ".gz")
+	if t, e := time.ParseInLocation(fileFormat, name, time.Local); e == nil {
+		return t, 0, true
+	}
+
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return time.Time{}, 0, false
+	}
+
+	index, e := strconv.Atoi(name[i+1:])
+	if e != nil || index < 1 {
+		return time.Time{}, 0, false
+	}
+
+	t, e := time.ParseInLocation(fileFormat, name[:i], time.Local)
+	return t, index, e == nil
+}
+
+// 压缩未压缩的轮转文件, 然后按 MaxBackups/MaxAge 删除旧文件
+func (r *RotateWriter) compressAndClean() {
+	list, e := r.backups()
+	if e != nil {
+		os.Stderr.WriteString("log rotate: " + e.Error() + "\n")
+		return
+	}
+
+	if r.opts.Compress {
+		for i := range list {
+			if strings.HasSuffix(list[i].name, ".gz") {
+				continue
+			}
+
+			if e := r.compressFile(list[i].name); e != nil {
+				os.Stderr.WriteString("log rotate: " + e.Error() + "\n")
+				continue
+			}
+
+			list[i].name += ".gz"
+		}
+	}
+
+	deadline := time.Now().Add(-r.opts.MaxAge)
+	for i, b := range list {
+		if (r.opts.MaxBackups > 0 && i >= r.opts.MaxBackups) ||
+			(r.opts.MaxAge > 0 && b.modTime.Before(deadline)) {
+			if e := os.Remove(path.Join(r.root, b.name)); e != nil && !os.IsNotExist(e) {
+				os.Stderr.WriteString("log rotate: " + e.Error() + "\n")
+			}
+		}
+	}
+}
+
+// 压缩为 <name>.gz 并删除原文件, 保留原文件的修改时间
+func (r *RotateWriter) compressFile(name string) error {
+	src := path.Join(r.root, name)
+	f, e := os.Open(src)
 	if e != nil {
 		return e
 	}
 
 	defer f.Close()
 
-	w, e := os.OpenFile(path.Join(r.root, r.currentName+".gz"),
-		os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+	fi, e := f.Stat()
 	if e != nil {
 		return e
 	}
-	defer w.Close()
 
-	gz, e := gzip.NewWriterLevel(w, gzip.BestCompression)
+	tmp := src + ".gz.tmp"
+	w, e := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
 	if e != nil {
 		return e
 	}
-	defer gz.Close()
 
-	gz.Comment = fmt.Sprintf("rotate compressed at %s", time.Now().Format(time.RFC3339))
+	if e := writeGzip(w, f); e != nil {
+		w.Close()
+		os.Remove(tmp)
+		return e
+	}
 
-	if _, e := io.Copy(gz, f); e != nil {
+	if e := w.Close(); e != nil {
+		os.Remove(tmp)
 		return e
 	}
 
-	if e := gz.Flush(); e != nil {
+	os.Chtimes(tmp, fi.ModTime(), fi.ModTime())
+	if e := os.Rename(tmp, src+".gz"); e != nil {
+		os.Remove(tmp)
 		return e
 	}
 
-	return nil
+	return os.Remove(src)
+}
+
+func writeGzip(w io.Writer, r io.Reader) error {
+	gz, e := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if e != nil {
+		return e
+	}
+
+	gz.Comment = fmt.Sprintf("rotate compressed at %s", time.Now().Format(time.RFC3339))
+
+	if _, e := io.Copy(gz, r); e != nil {
+		return e
+	}
+
+	return gz.Close()
+}
+
+func fileExists(name string) bool {
+	_, e := os.Lstat(name)
+	return e == nil
 }
@@ -0,0 +1,214 @@
+package ulog
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateWriterSize(t *testing.T) {
+	dir, format := t.TempDir(), "2006-01-02.log"
+
+	// 不属于日志的文件和过期的日志
+	os.WriteFile(path.Join(dir, "notes.txt"), []byte("keep"), 0644)
+	os.WriteFile(path.Join(dir, "2000-01-01.log"), []byte("old"), 0644)
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(path.Join(dir, "2000-01-01.log"), old, old)
+
+	w, e := NewRotateWriterOptions(dir, format, RotateOptions{
+		IntervalDay: 1,
+		MaxSize:     10,
+		Compress:    true,
+		Symlink:     "current.log",
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, e := w.WriteString("0123456789"); e != nil {
+			t.Fatal(e)
+		}
+	}
+	w.WriteString("tail")
+
+	if e := w.Close(); e != nil {
+		t.Fatal(e)
+	}
+
+	// 后台清理与写入并发, 关闭后再开启保留限制, 并固定修改时间, 序号越大越新
+	current := time.Now().Format(format)
+	for i := 1; i <= 5; i++ {
+		name := path.Join(dir, current+"."+strconv.Itoa(i))
+		if !fileExists(name) {
+			name += ".gz"
+		}
+
+		mt := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(name, mt, mt)
+	}
+	w.opts.MaxBackups, w.opts.MaxAge = 2, 24*time.Hour
+	w.compressAndClean()
+
+	entries, _ := os.ReadDir(dir)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	want := []string{current, current + ".4.gz", current + ".5.gz", "current.log", "notes.txt"}
+	sort.Strings(want)
+	if len(names) != len(want) {
+		t.Fatalf("files: %v", names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("files: %v", names)
+		}
+	}
+
+	if target, _ := os.Readlink(path.Join(dir, "current.log")); target != current {
+		t.Fatalf("symlink: %s", target)
+	}
+
+	f, _ := os.Open(path.Join(dir, current+".5.gz"))
+	defer f.Close()
+
+	gz, e := gzip.NewReader(f)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if data, _ := io.ReadAll(gz); string(data) != "0123456789" {
+		t.Fatalf("backup: %q", data)
+	}
+
+	if data, _ := os.ReadFile(path.Join(dir, current)); string(data) != "tail" {
+		t.Fatalf("current: %q", data)
+	}
+
+	// 清理后的序号不会复用, 文件名顺序与时间顺序一致
+	w, e = NewRotateWriterOptions(dir, format, RotateOptions{IntervalDay: 1, MaxSize: 10})
+	if e != nil {
+		t.Fatal(e)
+	}
+	w.WriteString("0123456789")
+	w.WriteString("next")
+	w.Close()
+
+	if fileExists(path.Join(dir, current+".1")) || !fileExists(path.Join(dir, current+".6")) {
+		entries, _ = os.ReadDir(dir)
+		t.Fatalf("index reused: %v", entries)
+	}
+}
+
+func TestRotateWriterReopen(t *testing.T) {
+	dir := t.TempDir()
+	w, e := NewRotateWriterOptions(dir, "2006-01-02.log", RotateOptions{IntervalDay: 1})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer w.Close()
+
+	name := path.Join(dir, time.Now().Format("2006-01-02.log"))
+	w.WriteString("before\n")
+
+	// 模拟 logrotate 移动文件
+	if e := os.Rename(name, name+".moved"); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := w.Reopen(); e != nil {
+		t.Fatal(e)
+	}
+	w.WriteString("after\n")
+
+	if data, _ := os.ReadFile(name); string(data) != "after\n" {
+		t.Fatalf("current: %q", data)
+	}
+
+	if data, _ := os.ReadFile(name + ".moved"); string(data) != "before\n" {
+		t.Fatalf("moved: %q", data)
+	}
+}
+
+// 关闭失败的文件
+type failCloser struct{}
+
+func (failCloser) Write(p []byte) (int, error) { return len(p), nil }
+func (failCloser) Close() error                { return errors.New("close failed") }
+
+func TestRotateWriterRecover(t *testing.T) {
+	dir := t.TempDir()
+	w, e := NewRotateWriterOptions(dir, "2006-01-02.log", RotateOptions{IntervalDay: 1})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// 关闭失败时不能保留已经关闭的文件句柄
+	w.rw.Lock()
+	w.current.Close()
+	w.current = failCloser{}
+	w.rw.Unlock()
+
+	if e := w.Reopen(); e == nil || !strings.Contains(e.Error(), "close failed") {
+		t.Fatalf("reopen: %v", e)
+	}
+
+	if _, ok := w.current.(*os.File); !ok {
+		t.Fatalf("current: %T", w.current)
+	}
+
+	// 打开失败后写入返回错误, 恢复后重新打开
+	w.rw.Lock()
+	w.currentName = "missing/app.log"
+	w.rw.Unlock()
+
+	if e := w.Reopen(); e == nil {
+		t.Fatal("reopen in missing directory")
+	}
+
+	if _, e := w.WriteString("lost\n"); e == nil {
+		t.Fatal("write without file")
+	}
+
+	os.Mkdir(path.Join(dir, "missing"), 0o755)
+	if _, e := w.WriteString("recovered\n"); e != nil {
+		t.Fatal(e)
+	}
+
+	if data, _ := os.ReadFile(path.Join(dir, "missing/app.log")); string(data) != "recovered\n" {
+		t.Fatalf("recovered: %q", data)
+	}
+
+	// 当前文件打开失败时关闭也要停止后台协程
+	w.rw.Lock()
+	w.closeCurrent()
+	w.rw.Unlock()
+
+	if e := w.Close(); e != nil {
+		t.Fatal(e)
+	}
+
+	select {
+	case <-w.exited:
+	default:
+		t.Fatal("goroutines not stopped")
+	}
+
+	if e := w.Close(); !errors.Is(e, ErrRotateClosed) {
+		t.Fatalf("close twice: %v", e)
+	}
+
+	if _, e := w.WriteString("closed\n"); !errors.Is(e, ErrRotateClosed) {
+		t.Fatalf("write after close: %v", e)
+	}
+}
@@ -0,0 +1,179 @@
+package ulog
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const maxSampleKeys = 4096 // 最多跟踪的调用位置数量, 超过后不再采样新的位置
+
+type SampleOptions struct {
+	Interval time.Duration // 补充周期, 默认 1s
+	Burst    int           // 每个调用位置最多连续输出的数量, 默认 10
+	Allow    int           // 每个周期补充的数量, 默认 1
+	Levels   Level         // 采样的等级, 默认 LevelWarn | LevelError, LevelFatal 不会被采样
+}
+
+// 重复日志采样, 按调用位置和格式化字符串去重
+// @description 每个调用位置使用令牌桶限制输出, 被抑制的日志不会格式化消息和获取调用栈,
+// 周期结束后输出 "suppressed N similar messages" 汇总
+type sampler struct {
+	opts    SampleOptions
+	now     func() time.Time
+	lock    sync.Mutex
+	sites   map[sampleKey]*sampleSite
+	frames  map[uintptr]runtime.Frame // 程序计数器对应的文件和行号, 内联的调用位置有多个程序计数器
+	pending bool                      // 已经安排了汇总输出
+}
+
+type sampleKey struct {
+	file   string
+	line   int
+	format string
+}
+
+type sampleSite struct {
+	tokens     int
+	last       time.Time // 上次补充的时间
+	suppressed int
+	level      Level
+	name       string
+}
+
+// 设置重复日志采样, 作用于根日志和所有子日志
+func (l *Logger) SetSampling(opts SampleOptions) {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+
+	if opts.Burst < 1 {
+		opts.Burst = 10
+	}
+
+	if opts.Allow < 1 {
+		opts.Allow = 1
+	}
+
+	if opts.Levels == LevelMuted {
+		opts.Levels = LevelWarn | LevelError
+	}
+	opts.Levels &^= LevelFatal
+
+	l.root().sampler.Store(&sampler{
+		opts:   opts,
+		now:    time.Now,
+		sites:  map[sampleKey]*sampleSite{},
+		frames: map[uintptr]runtime.Frame{},
+	})
+}
+
+// 关闭重复日志采样, 未输出的汇总会被丢弃
+func (l *Logger) DisableSampling() {
+	l.root().sampler.Store(nil)
+}
+
+// 是否输出, 只获取调用位置的程序计数器, 文件和行号会被缓存
+// @param skipCaller 相对于调用者的追溯层级, 与调用者中的 runtime.Caller 相同
+func (s *sampler) allow(l *Logger, level Level, skipCaller int, format string) bool {
+	pcs := [1]uintptr{}
+	if runtime.Callers(skipCaller+2, pcs[:]) < 1 {
+		return true
+	}
+
+	now := s.now()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	f, ok := s.frames[pcs[0]]
+	if !ok {
+		f, _ = runtime.CallersFrames(pcs[:]).Next()
+		if len(s.frames) < maxSampleKeys {
+			s.frames[pcs[0]] = f
+		}
+	}
+
+	key := sampleKey{file: f.File, line: f.Line, format: format}
+
+	site, ok := s.sites[key]
+	if !ok {
+		if len(s.sites) >= maxSampleKeys && !s.evict(now) {
+			return true
+		}
+
+		site = &sampleSite{tokens: s.opts.Burst, last: now}
+		s.sites[key] = site
+	}
+
+	if n := int(now.Sub(site.last) / s.opts.Interval); n > 0 {
+		site.tokens = min(s.opts.Burst, site.tokens+n*s.opts.Allow)
+		site.last = site.last.Add(time.Duration(n) * s.opts.Interval)
+	}
+
+	if site.tokens > 0 {
+		site.tokens--
+		return true
+	}
+
+	site.suppressed++
+	site.level, site.name = level, l.name
+
+	if !s.pending {
+		s.pending = true
+		time.AfterFunc(s.opts.Interval, func() { s.report(l.root()) })
+	}
+
+	return false
+}
+
+// 删除令牌桶已经补满并且没有抑制计数的调用位置, 它们与新的调用位置没有区别;
+// 令牌还在补充的调用位置需要保留, 否则重新创建后会获得完整的令牌
+// @return 是否有空位
+func (s *sampler) evict(now time.Time) bool {
+	for key, site := range s.sites {
+		n := int(now.Sub(site.last) / s.opts.Interval)
+		if site.suppressed == 0 && (n >= s.opts.Burst || site.tokens+n*s.opts.Allow >= s.opts.Burst) {
+			delete(s.sites, key)
+		}
+	}
+
+	return len(s.sites) < maxSampleKeys
+}
+
+// 输出所有调用位置的抑制汇总
+func (s *sampler) report(root *Logger) {
+	type summary struct {
+		key  sampleKey
+		site sampleSite
+	}
+
+	s.lock.Lock()
+	list := []summary{}
+	for key, site := range s.sites {
+		if site.suppressed > 0 {
+			list = append(list, summary{key: key, site: *site})
+			site.suppressed = 0
+		}
+	}
+	s.pending = false
+	s.lock.Unlock()
+
+	// 采样已经关闭或被替换
+	if root.sampler.Load() != s {
+		return
+	}
+
+	for _, item := range list {
+		g := root.logPool.Get().(*Log)
+		g.Level = item.site.level
+		g.Message = fmt.Sprintf("suppressed %d similar messages: %s", item.site.suppressed, item.key.format)
+		g.File, g.Line = item.key.file, item.key.line
+		g.Time = time.Now()
+		g.Fields = append(g.Fields[:0], Field{"suppressed", item.site.suppressed})
+		g.Name = item.site.name
+
+		root.Writer(g)
+	}
+}
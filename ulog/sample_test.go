@@ -0,0 +1,87 @@
+package ulog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// 记录格式化次数
+type countStringer struct {
+	n int
+}
+
+func (cs *countStringer) String() string {
+	cs.n++
+	return "db"
+}
+
+func TestSampling(t *testing.T) {
+	cf := &captureFormat{}
+	l := NewLogger(cf)
+	l.SetSampling(SampleOptions{Interval: time.Hour, Burst: 3, Allow: 2})
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := l.sampler.Load()
+	s.now = func() time.Time { return now }
+
+	cs := &countStringer{}
+	flood := func(n int) {
+		for i := 0; i < n; i++ {
+			l.With("i", i).Error("%s down", cs)
+		}
+	}
+
+	flood(100)
+	l.Info("not sampled")
+	l.Warn("other site")
+
+	if len(cf.logs) != 5 || cs.n != 3 {
+		t.Fatalf("logs: %d formatted: %d", len(cf.logs), cs.n)
+	}
+
+	// 两个周期补充 4 条, 最多 3 条
+	now = now.Add(2 * time.Hour)
+	flood(10)
+	if cs.n != 6 {
+		t.Fatalf("formatted after refill: %d", cs.n)
+	}
+
+	cf.logs = nil
+	s.report(l)
+
+	if len(cf.logs) != 1 {
+		t.Fatalf("summaries: %d", len(cf.logs))
+	}
+
+	g := cf.logs[0]
+	if g.Level != LevelError || g.Message != "suppressed 104 similar messages: %s down" ||
+		!strings.HasSuffix(g.File, "sample_test.go") || g.Fields[0].Value != 104 {
+		t.Fatalf("summary: %+v", g)
+	}
+
+	cf.logs = nil
+	l.DisableSampling()
+	flood(5)
+	if len(cf.logs) != 5 {
+		t.Fatalf("logs after disable: %d", len(cf.logs))
+	}
+}
+
+func TestSamplingEvict(t *testing.T) {
+	s := &sampler{opts: SampleOptions{Interval: time.Second, Burst: 3, Allow: 1}, sites: map[sampleKey]*sampleSite{}}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 令牌已经用完但是没有抑制计数的调用位置
+	for i := 0; i < maxSampleKeys; i++ {
+		s.sites[sampleKey{line: i}] = &sampleSite{tokens: 0, last: now}
+	}
+
+	if s.evict(now.Add(2*time.Second)) || len(s.sites) != maxSampleKeys {
+		t.Fatalf("refilling sites evicted: %d", len(s.sites))
+	}
+
+	if !s.evict(now.Add(3*time.Second)) || len(s.sites) != 0 {
+		t.Fatalf("refilled sites kept: %d", len(s.sites))
+	}
+}
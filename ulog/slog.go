@@ -0,0 +1,181 @@
+package ulog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+const (
+	SlogLevelTrace = slog.LevelDebug - 4 // LevelTrace 对应的 slog 等级
+	SlogLevelFatal = slog.LevelError + 4 // LevelFatal 对应的 slog 等级
+)
+
+// ulog 等级转换为 slog 等级, LevelPrintf 对应 slog.LevelInfo
+func ToSlogLevel(level Level) slog.Level {
+	switch level {
+	case LevelTrace:
+		return SlogLevelTrace
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	case LevelFatal:
+		return SlogLevelFatal
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// slog 等级转换为 ulog 等级, 按区间向下取整, 例如 slog.LevelInfo+2 为 LevelInfo
+func FromSlogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return LevelTrace
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	case level < SlogLevelFatal:
+		return LevelError
+	default:
+		return LevelFatal
+	}
+}
+
+// slog.Handler, 将 slog 的日志写入 Logger 的格式化接口
+type slogHandler struct {
+	l      *Logger
+	prefix string  // 分组前缀, 例如 "http.request."
+	attrs  []Field // WithAttrs 的字段
+}
+
+// 将 Logger 作为 slog.Handler 使用, 例如 slog.SetDefault(slog.New(ulog.GlobalLogger().Handler()))
+// @description 分组使用 . 连接的键, 与 slog.TextHandler 相同, Fatal 等级不会 panic
+func (l *Logger) Handler() slog.Handler {
+	return &slogHandler{l: l}
+}
+
+// 等级由模块等级和格式化接口过滤
+func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.l.Enabled(FromSlogLevel(level))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	root := h.l.root()
+
+	g := root.logPool.Get().(*Log)
+	g.Level = FromSlogLevel(r.Level)
+	g.Message = r.Message
+	g.File, g.Line = "", 0
+	g.Name = h.l.name
+	g.Time = r.Time
+	if g.Time.IsZero() {
+		g.Time = time.Now()
+	}
+
+	if r.PC != 0 {
+		f, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		g.File, g.Line = f.File, f.Line
+	}
+
+	g.Fields = append(append(g.Fields[:0], h.l.fields...), h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		g.Fields = appendAttr(g.Fields, h.prefix, a)
+		return true
+	})
+
+	h.l.Writer(g)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := append([]Field(nil), h.attrs...)
+	for _, a := range attrs {
+		fields = appendAttr(fields, h.prefix, a)
+	}
+
+	return &slogHandler{l: h.l, prefix: h.prefix, attrs: fields}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &slogHandler{l: h.l, prefix: h.prefix + name + ".", attrs: h.attrs}
+}
+
+// 将 slog 属性展开为字段, 分组使用 . 连接
+func appendAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		if len(group) < 1 {
+			return fields
+		}
+
+		// 空键的分组直接展开
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+
+		for _, ga := range group {
+			fields = appendAttr(fields, prefix, ga)
+		}
+
+		return fields
+	default:
+		return append(fields, Field{Key: prefix + a.Key, Value: a.Value.Any()})
+	}
+}
+
+// 将 slog.Handler 作为格式化接口使用
+// @description 字段转换为 slog 属性, 追溯文件和行号转换为 slog.SourceKey 属性, 调用栈和错误链转换为 stack 和 causes 属性
+type SlogFormat struct {
+	handler slog.Handler
+}
+
+func NewSlogFormat(handler slog.Handler) *SlogFormat {
+	return &SlogFormat{handler: handler}
+}
+
+func (sf *SlogFormat) Write(log *Log) {
+	level, ctx := ToSlogLevel(log.Level), context.Background()
+	if !sf.handler.Enabled(ctx, level) {
+		return
+	}
+
+	r := slog.NewRecord(log.Time, level, CleanANSI(log.Message), 0)
+	if log.File != "" {
+		r.AddAttrs(slog.Any(slog.SourceKey, &slog.Source{File: log.File, Line: log.Line}))
+	}
+
+	if log.Name != "" {
+		r.AddAttrs(slog.String("logger", log.Name))
+	}
+
+	for _, f := range log.Fields {
+		r.AddAttrs(slog.Any(f.Key, f.Value))
+	}
+
+	if len(log.Stack) > 0 {
+		r.AddAttrs(slog.Any("stack", log.Stack))
+	}
+
+	if len(log.Causes) > 0 {
+		r.AddAttrs(slog.Any("causes", log.Causes))
+	}
+
+	sf.handler.Handle(ctx, r)
+}
@@ -0,0 +1,75 @@
+package ulog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	cf := &captureFormat{}
+	l := NewLogger(cf)
+
+	sl := slog.New(l.With("app", "uw").Handler()).With("req_id", 7).WithGroup("http")
+	sl.Warn("slow request", "path", "/a", slog.Group("resp", "code", 200))
+	sl.Log(context.Background(), SlogLevelTrace, "trace")
+
+	if len(cf.logs) != 2 {
+		t.Fatalf("logs: %d", len(cf.logs))
+	}
+
+	g := cf.logs[0]
+	if g.Level != LevelWarn || g.Message != "slow request" || !strings.HasSuffix(g.File, "slog_test.go") {
+		t.Fatalf("log: %+v", g)
+	}
+
+	want := []Field{{"app", "uw"}, {"req_id", int64(7)}, {"http.path", "/a"}, {"http.resp.code", int64(200)}}
+	if len(g.Fields) != len(want) {
+		t.Fatalf("fields: %+v", g.Fields)
+	}
+	for i := range want {
+		if g.Fields[i] != want[i] {
+			t.Fatalf("field %d: %+v", i, g.Fields[i])
+		}
+	}
+
+	if cf.logs[1].Level != LevelTrace {
+		t.Fatalf("trace level: %d", cf.logs[1].Level)
+	}
+}
+
+func TestSlogFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewLogger(NewSlogFormat(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	l.Trace("dropped")
+	l.With("user", "qaq").Info("hello %s", "world", "n", 1)
+
+	v := map[string]any{}
+	if e := json.Unmarshal(buf.Bytes(), &v); e != nil {
+		t.Fatalf("%s: %s", e, buf)
+	}
+
+	if v["level"] != "INFO" || v["msg"] != "hello world" || v["user"] != "qaq" || v["n"] != float64(1) {
+		t.Fatalf("record: %v", v)
+	}
+
+	if src, ok := v[slog.SourceKey].(map[string]any); !ok || !strings.HasSuffix(src["file"].(string), "slog_test.go") {
+		t.Fatalf("source: %v", v[slog.SourceKey])
+	}
+}
+
+func TestSlogLevel(t *testing.T) {
+	for _, level := range []Level{LevelTrace, LevelDebug, LevelInfo, LevelWarn, LevelError, LevelFatal} {
+		if got := FromSlogLevel(ToSlogLevel(level)); got != level {
+			t.Fatalf("%s: %s", LevelName(level), LevelName(got))
+		}
+	}
+
+	if FromSlogLevel(slog.LevelInfo+2) != LevelInfo {
+		t.Fatal("level between info and warn")
+	}
+}
@@ -0,0 +1,216 @@
+package ulog
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxStackDepth = 64 // 结构化调用栈的最大深度
+
+// 调用栈帧
+type Frame struct {
+	Function string `json:"func"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// 错误链中的一个错误
+type Cause struct {
+	Type    string `json:"type"` // 错误类型, 例如 *fs.PathError
+	Message string `json:"msg"`
+}
+
+// 调用栈的渲染方式, 由格式化接口决定
+type StackMode uint8
+
+const (
+	StackAuto   StackMode = iota // 格式化接口的默认方式, 文本为 StackInline, JSON 为 StackArray
+	StackInline                  // 多行文本
+	StackArray                   // 结构化数组, 只有 JSON 支持, 文本格式化使用 StackInline
+	StackNone                    // 不输出调用栈和错误链
+)
+
+// 结构化调用栈
+// @param maxDepth 最大深度
+// @param skip 跳过的层级, 0 为调用 StackFrames 的函数
+func StackFrames(maxDepth, skip int) []Frame {
+	return appendFrames(nil, maxDepth, skip+1)
+}
+
+func appendFrames(frames []Frame, maxDepth, skip int) []Frame {
+	pcs := make([]uintptr, maxDepth)
+	n := runtime.Callers(skip+2, pcs)
+	if n < 1 {
+		return frames
+	}
+
+	iter := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := iter.Next()
+		frames = append(frames, Frame{Function: f.Function, File: f.File, Line: f.Line})
+
+		if !more {
+			return frames
+		}
+	}
+}
+
+// 展开错误链, 深度优先, 支持 errors.Unwrap 和 errors.Join
+func ErrorCauses(e error) []Cause {
+	return appendCauses(nil, e, 0)
+}
+
+func appendCauses(causes []Cause, e error, depth int) []Cause {
+	if e == nil || depth > 32 {
+		return causes
+	}
+
+	causes = append(causes, Cause{Type: reflect.TypeOf(e).String(), Message: e.Error()})
+
+	if joined, ok := e.(interface{ Unwrap() []error }); ok {
+		for _, je := range joined.Unwrap() {
+			causes = appendCauses(causes, je, depth+1)
+		}
+
+		return causes
+	}
+
+	return appendCauses(causes, errors.Unwrap(e), depth+1)
+}
+
+// 参数和字段中所有错误的错误链
+func argsCauses(causes []Cause, args []interface{}, fields []Field) []Cause {
+	for _, arg := range args {
+		if e, ok := arg.(error); ok {
+			causes = appendCauses(causes, e, 0)
+		}
+	}
+
+	for _, f := range fields {
+		if e, ok := f.Value.(error); ok {
+			causes = appendCauses(causes, e, 0)
+		}
+	}
+
+	return causes
+}
+
+// 多行文本的调用栈和错误链, 没有时为空
+func formatStack(log *Log) string {
+	if len(log.Stack) < 1 && len(log.Causes) < 1 {
+		return ""
+	}
+
+	b := &strings.Builder{}
+	if len(log.Causes) > 0 {
+		b.WriteString("\r\nCaused by:")
+		for i, c := range log.Causes {
+			b.WriteString("\r\n" + strconv.Itoa(i+1) + ". " + c.Message + " (" + c.Type + ")")
+		}
+	}
+
+	if len(log.Stack) > 0 {
+		b.WriteString("\r\nError Stack:")
+		for i, f := range log.Stack {
+			b.WriteString("\r\n" + strconv.Itoa(i+1) + "." + f.Function + "\r\n\t" + f.File + ":" + strconv.Itoa(f.Line))
+		}
+	}
+
+	return b.String()
+}
+
+// 去掉 recover 相关的帧, 从 panic 的位置开始
+// @description 在 defer 中重新抛出的 panic 有多个 runtime.gopanic, 最后一个是最初的 panic,
+// 之后的 runtime.panicmem 和 runtime.sigpanic 等运行时帧也会去掉
+func panicFrames(frames []Frame) []Frame {
+	for i := len(frames) - 1; i >= 0; i-- {
+		if frames[i].Function != "runtime.gopanic" {
+			continue
+		}
+
+		frames = frames[i+1:]
+		for len(frames) > 1 && strings.HasPrefix(frames[0].Function, "runtime.") {
+			frames = frames[1:]
+		}
+		break
+	}
+
+	return frames
+}
+
+// 记录 panic 并恢复, 使用 defer ulog.Recover() 调用
+// @description http.ErrAbortHandler 和 panic(nil) 等流程控制的 panic 会继续抛出
+func Recover() {
+	if r := recover(); r != nil {
+		globalLogger.LogPanic(r)
+	}
+}
+
+// 记录 panic 并恢复, 使用 defer l.Recover() 调用, 例如 defer ulog.FromContext(ctx).Recover()
+// @description http.ErrAbortHandler 和 panic(nil) 等流程控制的 panic 会继续抛出
+func (l *Logger) Recover() {
+	if r := recover(); r != nil {
+		l.LogPanic(r)
+	}
+}
+
+// 记录 panic 并转换为错误, 用于返回错误的处理函数, 例如 uboot 模块:
+//
+//	func(c *uboot.Context) (e error) {
+//		defer c.Logger().RecoverError(&e)
+//		...
+//	}
+func (l *Logger) RecoverError(e *error) {
+	if r := recover(); r != nil {
+		l.LogPanic(r)
+
+		if re, ok := r.(error); ok {
+			*e = fmt.Errorf("panic: %w", re)
+		} else {
+			*e = fmt.Errorf("panic: %v", r)
+		}
+	}
+}
+
+// 记录已经恢复的 panic, 调用栈从 panic 的位置开始, 用于需要自行处理 recover 结果的场景, 例如返回 500 响应
+// @description http.ErrAbortHandler 和 panic(nil) 等流程控制的 panic 会继续抛出
+// @param r recover() 的返回值
+func (l *Logger) LogPanic(r interface{}) {
+	var nilPanic *runtime.PanicNilError
+	if re, ok := r.(error); ok && (errors.Is(re, http.ErrAbortHandler) || errors.As(re, &nilPanic)) {
+		panic(r)
+	}
+
+	if !l.Enabled(LevelError) {
+		return
+	}
+
+	frames := panicFrames(StackFrames(maxStackDepth, 1))
+
+	g := l.root().logPool.Get().(*Log)
+	g.Level = LevelError
+	g.Message = fmt.Sprint(r)
+	if !strings.HasPrefix(g.Message, "panic: ") {
+		g.Message = "panic: " + g.Message
+	}
+	g.File, g.Line = "", 0
+	if len(frames) > 0 {
+		g.File, g.Line = frames[0].File, frames[0].Line
+	}
+	g.Time = time.Now()
+	g.Fields = append(g.Fields[:0], l.fields...)
+	g.Name = l.name
+	g.Stack = append(g.Stack[:0], frames...)
+	g.Causes = g.Causes[:0]
+	if re, ok := r.(error); ok {
+		g.Causes = appendCauses(g.Causes, re, 0)
+	}
+
+	l.Writer(g)
+}
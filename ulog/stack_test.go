@@ -0,0 +1,128 @@
+package ulog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestErrorStack(t *testing.T) {
+	cf := &captureFormat{}
+	l := NewLogger(cf)
+
+	base := &fs.PathError{Op: "open", Path: "/etc/app.toml", Err: fs.ErrNotExist}
+	e := errors.Join(fmt.Errorf("load config: %w", base), errors.New("fallback failed"))
+	l.Error("boot failed: %v", e, "uint", "config")
+
+	g := cf.logs[0]
+	if strings.Contains(g.Message, "Error Stack") || len(g.Fields) != 1 {
+		t.Fatalf("message: %q %v", g.Message, g.Fields)
+	}
+
+	if len(g.Stack) < 1 || g.Stack[0].Function != "uw/ulog.TestErrorStack" || g.Stack[0].Line != g.Line {
+		t.Fatalf("stack: %+v", g.Stack)
+	}
+
+	want := []string{"*errors.joinError", "*fmt.wrapError", "*fs.PathError", "*errors.errorString", "*errors.errorString"}
+	if len(g.Causes) != len(want) {
+		t.Fatalf("causes: %+v", g.Causes)
+	}
+
+	for i, c := range g.Causes {
+		if c.Type != want[i] {
+			t.Fatalf("cause %d: %+v", i, c)
+		}
+	}
+
+	if g.Causes[3].Message != "file does not exist" || g.Causes[4].Message != "fallback failed" {
+		t.Fatalf("causes: %+v", g.Causes)
+	}
+
+	// 对象池中的日志不能带上一次的调用栈
+	l.Info("next")
+	if len(cf.logs[1].Stack) != 0 || len(cf.logs[1].Causes) != 0 {
+		t.Fatalf("pooled log: %+v", cf.logs[1])
+	}
+}
+
+func TestStackMode(t *testing.T) {
+	g := &Log{
+		Level:   LevelError,
+		Message: "failed",
+		Stack:   []Frame{{Function: "main.run", File: "/app/main.go", Line: 12}},
+		Causes:  []Cause{{Type: "*errors.errorString", Message: "boom"}},
+	}
+
+	df := NewDefaultFormat(nil)
+	df.SetLevel(DefaultLevelNoColor)
+	if s := df.Format(g); !strings.Contains(s, "failed\r\nCaused by:\r\n1. boom (*errors.errorString)\r\nError Stack:\r\n1.main.run\r\n\t/app/main.go:12\r\n") {
+		t.Fatalf("inline: %q", s)
+	}
+
+	df.SetStackMode(StackNone)
+	if s := df.Format(g); strings.Contains(s, "Stack") {
+		t.Fatalf("none: %q", s)
+	}
+
+	jf := NewJSONFormat(nil)
+	v := map[string]interface{}{}
+	if e := json.Unmarshal([]byte(jf.Format(g)), &v); e != nil {
+		t.Fatal(e)
+	}
+
+	stack, _ := v["stack"].([]interface{})
+	causes, _ := v["causes"].([]interface{})
+	if len(stack) != 1 || stack[0].(map[string]interface{})["func"] != "main.run" || len(causes) != 1 {
+		t.Fatalf("array: %v", v)
+	}
+
+	jf.SetStackMode(StackInline)
+	v = map[string]interface{}{}
+	json.Unmarshal([]byte(jf.Format(g)), &v)
+	if v["stack"] != "main.run\n\t/app/main.go:12" {
+		t.Fatalf("inline json: %v", v)
+	}
+
+	jf.SetStackMode(StackNone)
+	if s := jf.Format(g); strings.Contains(s, "stack") || strings.Contains(s, "causes") {
+		t.Fatalf("none json: %s", s)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	cf := &captureFormat{}
+	l := NewLogger(cf)
+
+	func() {
+		defer l.Recover()
+		panic(errors.New("nil map"))
+	}()
+
+	g := cf.logs[0]
+	if g.Message != "panic: nil map" || len(g.Causes) != 1 ||
+		len(g.Stack) < 1 || !strings.HasPrefix(g.Stack[0].Function, "uw/ulog.TestRecover.func") {
+		t.Fatalf("recover: %+v", g)
+	}
+
+	run := func() (e error) {
+		defer l.RecoverError(&e)
+		panic("index out of range")
+	}
+
+	if e := run(); e == nil || e.Error() != "panic: index out of range" || len(cf.logs) != 2 {
+		t.Fatalf("recover error: %v", e)
+	}
+
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler || len(cf.logs) != 2 {
+			t.Fatalf("abort handler: %v", r)
+		}
+	}()
+
+	defer l.Recover()
+	panic(http.ErrAbortHandler)
+}
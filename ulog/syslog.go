@@ -0,0 +1,457 @@
+package ulog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+type SyslogProtocol uint8
+
+const (
+	RFC5424 SyslogProtocol = iota // <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+	RFC3164                       // <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+)
+
+// syslog 设施
+const (
+	FacilityKern   = 0
+	FacilityUser   = 1
+	FacilityDaemon = 3
+	FacilityLocal0 = 16
+	FacilityLocal1 = 17
+	FacilityLocal2 = 18
+	FacilityLocal3 = 19
+	FacilityLocal4 = 20
+	FacilityLocal5 = 21
+	FacilityLocal6 = 22
+	FacilityLocal7 = 23
+)
+
+// 结构化数据的 SD-ID, 32473 为 RFC 5612 中用于文档的企业编号
+const syslogSDID = "ulog@32473"
+
+// 连接可用时同一条消息的最大发送次数, 超过后丢弃, 避免一条无法发送的消息阻塞后续消息
+const syslogSendAttempts = 3
+
+var ErrSyslogClosed = errors.New("syslog format closed")
+
+type SyslogOptions struct {
+	Network      string                   // udp (默认), tcp, unix 或 unixgram, tcp 和 unix 使用 octet counting 分帧
+	Addr         string                   // 收集器地址, 例如 127.0.0.1:514 或 /dev/log
+	Protocol     SyslogProtocol           // 消息格式, 默认 RFC5424
+	Facility     int                      // 设施, 默认 FacilityUser, 应用不能使用 FacilityKern
+	AppName      string                   // 应用名称, 默认程序文件名
+	Hostname     string                   // 主机名, 默认 os.Hostname
+	Severity     func(level Level) int    // 等级对应的严重程度, 默认 DefaultSyslogSeverity
+	Spool        int                      // 无法发送时缓存的消息数量, 超过后丢弃最旧的消息, 默认 1024
+	MaxDatagram  int                      // udp 和 unixgram 单条消息的最大字节数, 超过后截断, 默认 2048
+	MinBackoff   time.Duration            // 重连的最小间隔, 默认 100ms
+	MaxBackoff   time.Duration            // 重连的最大间隔, 默认 30s
+	FlushTimeout time.Duration            // Flush 等待缓存发送完成的时长, 默认 5s
+	Level        Level                    // 输出的等级, 默认 DefaultLevelNoColor
+	Dial         func() (net.Conn, error) // 自定义连接, 设置后忽略 Network 和 Addr
+}
+
+// syslog 格式化, 将日志发送到 syslog 收集器
+// @description 日志在 Write 中格式化后放入有界缓存, 由后台协程发送;
+// 连接失败或写入失败时按指数退避重连, 期间的日志保留在缓存中, 连接可用时多次写入失败的消息被丢弃
+type SyslogFormat struct {
+	opts    SyslogOptions
+	framed  bool // 使用 octet counting 分帧
+	pid     string
+	lock    sync.Mutex
+	spool   [][]byte
+	notify  chan struct{} // 缓存有新消息
+	drained chan struct{} // 缓存发送完成, 由 Flush 等待
+	closed  chan struct{}
+	exited  chan struct{}
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// 默认等级对应的 syslog 严重程度
+// @description Fatal 为 2 (crit), Error 为 3 (err), Warn 为 4 (warning), Info 和 Printf 为 6 (info),
+// Debug 和 Trace 为 7 (debug)
+func DefaultSyslogSeverity(level Level) int {
+	switch level {
+	case LevelFatal:
+		return 2
+	case LevelError:
+		return 3
+	case LevelWarn:
+		return 4
+	case LevelDebug, LevelTrace:
+		return 7
+	default:
+		return 6
+	}
+}
+
+func NewSyslogFormat(opts SyslogOptions) (*SyslogFormat, error) {
+	if opts.Network == "" {
+		opts.Network = "udp"
+	}
+
+	framed := false
+	switch opts.Network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		framed = true
+	case "udp", "udp4", "udp6", "unixgram":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network: %s", opts.Network)
+	}
+
+	if opts.Facility < 0 || opts.Facility > FacilityLocal7 {
+		return nil, fmt.Errorf("invalid syslog facility: %d", opts.Facility)
+	} else if opts.Facility == FacilityKern {
+		opts.Facility = FacilityUser
+	}
+
+	if opts.AppName == "" {
+		opts.AppName = path.Base(os.Args[0])
+	}
+
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+
+	if opts.Severity == nil {
+		opts.Severity = DefaultSyslogSeverity
+	}
+
+	if opts.Spool < 1 {
+		opts.Spool = 1024
+	}
+
+	if opts.MaxDatagram < 1 {
+		opts.MaxDatagram = 2048
+	}
+
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 100 * time.Millisecond
+	}
+
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = 30 * time.Second
+	}
+
+	if opts.FlushTimeout <= 0 {
+		opts.FlushTimeout = 5 * time.Second
+	}
+
+	if opts.Level == LevelMuted {
+		opts.Level = DefaultLevelNoColor
+	}
+
+	if opts.Dial == nil {
+		opts.Dial = func() (net.Conn, error) {
+			return net.DialTimeout(opts.Network, opts.Addr, 5*time.Second)
+		}
+	}
+
+	sf := &SyslogFormat{
+		opts:    opts,
+		framed:  framed,
+		pid:     strconv.Itoa(os.Getpid()),
+		notify:  make(chan struct{}, 1),
+		drained: make(chan struct{}),
+		closed:  make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+
+	close(sf.drained)
+	go sf.sender()
+	return sf, nil
+}
+
+func (sf *SyslogFormat) Write(log *Log) {
+	if sf.opts.Level&log.Level == 0 && LevelFatal&log.Level == 0 {
+		return
+	}
+
+	msg := []byte(sf.Format(log))
+	if !sf.framed && len(msg) > sf.opts.MaxDatagram {
+		msg = truncateUTF8(msg, sf.opts.MaxDatagram)
+	}
+
+	sf.lock.Lock()
+	select {
+	case <-sf.closed:
+		sf.lock.Unlock()
+		return
+	default:
+	}
+
+	// 缓存为空时 drained 已经关闭, 在丢弃最旧的消息之前判断, 避免替换还有 Flush 等待的通道
+	if len(sf.spool) < 1 {
+		sf.drained = make(chan struct{})
+	}
+
+	if len(sf.spool) >= sf.opts.Spool {
+		sf.spool = sf.spool[1:]
+		sf.dropped.Add(1)
+	}
+
+	sf.spool = append(sf.spool, msg)
+	sf.lock.Unlock()
+
+	select {
+	case sf.notify <- struct{}{}:
+	default:
+	}
+}
+
+// 缓存满时丢弃的消息总数
+func (sf *SyslogFormat) Dropped() uint64 {
+	return sf.dropped.Load()
+}
+
+// 等待缓存中的消息发送完成, 超过 FlushTimeout 返回错误
+func (sf *SyslogFormat) Flush() error {
+	sf.lock.Lock()
+	drained := sf.drained
+	sf.lock.Unlock()
+
+	t := time.NewTimer(sf.opts.FlushTimeout)
+	defer t.Stop()
+
+	select {
+	case <-drained:
+		return nil
+	case <-sf.exited:
+		return ErrSyslogClosed
+	case <-t.C:
+		return fmt.Errorf("syslog flush timeout, %d messages pending", sf.pending())
+	}
+}
+
+// 尝试发送缓存中的消息, 然后关闭连接
+func (sf *SyslogFormat) Close() error {
+	e := sf.Flush()
+
+	sf.once.Do(func() {
+		close(sf.closed)
+	})
+
+	<-sf.exited
+	return e
+}
+
+func (sf *SyslogFormat) pending() int {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	return len(sf.spool)
+}
+
+func (sf *SyslogFormat) sender() {
+	defer close(sf.exited)
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	// 连续发送失败的消息和次数, 连接失败不计入
+	var failed *byte
+	failures := 0
+
+	backoff := sf.opts.MinBackoff
+	retry := func(e error) bool {
+		os.Stderr.WriteString("ulog syslog: " + e.Error() + "\n")
+
+		t := time.NewTimer(backoff)
+		defer t.Stop()
+
+		backoff = min(backoff*2, sf.opts.MaxBackoff)
+
+		select {
+		case <-sf.closed:
+			return false
+		case <-t.C:
+			return true
+		}
+	}
+
+	for {
+		sf.lock.Lock()
+		var msg []byte
+		if len(sf.spool) > 0 {
+			msg = sf.spool[0]
+		}
+		sf.lock.Unlock()
+
+		if msg == nil {
+			select {
+			case <-sf.closed:
+				return
+			case <-sf.notify:
+			}
+			continue
+		}
+
+		if conn == nil {
+			c, e := sf.opts.Dial()
+			if e != nil {
+				if !retry(e) {
+					return
+				}
+				continue
+			}
+
+			conn = c
+		}
+
+		if e := sf.send(conn, msg); e != nil {
+			conn.Close()
+			conn = nil
+
+			if failed != &msg[0] {
+				failed, failures = &msg[0], 0
+			}
+
+			if failures++; failures >= syslogSendAttempts {
+				os.Stderr.WriteString("ulog syslog: drop message after " + strconv.Itoa(failures) +
+					" failed attempts: " + e.Error() + "\n")
+				sf.dropped.Add(1)
+				sf.remove(msg)
+				continue
+			}
+
+			if !retry(e) {
+				return
+			}
+			continue
+		}
+
+		backoff = sf.opts.MinBackoff
+		sf.remove(msg)
+	}
+}
+
+// 从缓存中移除已发送或丢弃的消息, 缓存为空时通知 Flush
+func (sf *SyslogFormat) remove(msg []byte) {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	// 发送期间最旧的消息可能已经被丢弃
+	if len(sf.spool) > 0 && &sf.spool[0][0] == &msg[0] {
+		sf.spool = sf.spool[1:]
+	}
+
+	if len(sf.spool) < 1 {
+		sf.spool = nil
+		close(sf.drained)
+	}
+}
+
+func (sf *SyslogFormat) send(conn net.Conn, msg []byte) error {
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+
+	if sf.framed {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	_, e := conn.Write(msg)
+	return e
+}
+
+// 格式化为一条 syslog 消息, 不包含分帧
+func (sf *SyslogFormat) Format(log *Log) string {
+	pri := sf.opts.Facility*8 + sf.opts.Severity(log.Level)
+	message := strings.TrimRight(CleanANSI(log.Message), "\r\n")
+
+	b := &bytes.Buffer{}
+	if sf.opts.Protocol == RFC3164 {
+		fmt.Fprintf(b, "<%d>%s %s %s[%s]: %s%s", pri,
+			log.Time.Format(time.Stamp), syslogField(sf.opts.Hostname, 255),
+			sf.opts.AppName, sf.pid, message, formatFields(log.Fields, func(k string) string { return k }))
+		return b.String()
+	}
+
+	msgID := "-"
+	if log.Name != "" {
+		msgID = syslogField(log.Name, 32)
+	}
+
+	fmt.Fprintf(b, "<%d>1 %s %s %s %s %s ", pri,
+		log.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogField(sf.opts.Hostname, 255), syslogField(sf.opts.AppName, 48),
+		syslogField(sf.pid, 128), msgID)
+
+	b.WriteString("[" + syslogSDID)
+	if log.File != "" {
+		writeSDParam(b, "caller", path.Base(log.File)+":"+strconv.Itoa(log.Line))
+	}
+	for _, f := range log.Fields {
+		writeSDParam(b, f.Key, fmt.Sprint(f.Value))
+	}
+	b.WriteString("] ")
+
+	b.WriteString(message)
+	return b.String()
+}
+
+// 截断到 max 字节以内, 不拆分 UTF-8 字符
+func truncateUTF8(b []byte, max int) []byte {
+	n := max
+	for n > 0 && !utf8.RuneStart(b[n]) {
+		n--
+	}
+
+	return b[:n]
+}
+
+// 头部字段只能包含可打印的 ASCII 字符, 空值为 -
+func syslogField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+
+		return r
+	}, s)
+
+	if len(s) > max {
+		s = s[:max]
+	}
+
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+// 写入结构化数据参数, 名称去掉不允许的字符, 值转义 " \ ]
+func writeSDParam(b *bytes.Buffer, key, value string) {
+	key = syslogField(strings.Map(func(r rune) rune {
+		if r == '=' || r == ']' || r == '"' {
+			return -1
+		}
+
+		return r
+	}, key), 32)
+
+	b.WriteString(" " + key + `="`)
+	for _, r := range value {
+		if r == '"' || r == '\\' || r == ']' {
+			b.WriteByte('\\')
+		}
+
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+}
@@ -0,0 +1,238 @@
+package ulog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func testSyslogLog(message string) *Log {
+	return &Log{
+		Level:   LevelWarn,
+		File:    "/src/main.go",
+		Line:    7,
+		Message: SetANSI(ANSI.Red, message),
+		Time:    time.Date(2024, 3, 5, 6, 7, 8, 9000, time.UTC),
+		Fields:  []Field{{"user", `q"a]q`}, {"bad key", 1}},
+		Name:    "upg.pool",
+	}
+}
+
+func TestSyslogFormat(t *testing.T) {
+	sf, e := NewSyslogFormat(SyslogOptions{Addr: "127.0.0.1:1", AppName: "app", Hostname: "host", Facility: FacilityLocal0})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer sf.Close()
+
+	want := regexp.QuoteMeta(`<132>1 2024-03-05T06:07:08.000009Z host app `) + `\d+` +
+		regexp.QuoteMeta(` upg.pool [ulog@32473 caller="main.go:7" user="q\"a\]q" badkey="1"] disk full`)
+	if s := sf.Format(testSyslogLog("disk full\r\n")); !regexp.MustCompile("^" + want + "$").MatchString(s) {
+		t.Fatalf("rfc5424: %q", s)
+	}
+
+	sf.opts.Protocol = RFC3164
+	sf.opts.Severity = func(level Level) int { return 0 }
+	want = regexp.QuoteMeta(`<128>Mar  5 06:07:08 host app[`) + `\d+` + regexp.QuoteMeta(`]: disk full user="q\"a]q" bad key=1`)
+	if s := sf.Format(testSyslogLog("disk full")); !regexp.MustCompile("^" + want + "$").MatchString(s) {
+		t.Fatalf("rfc3164: %q", s)
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pc, e := net.ListenPacket("udp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer pc.Close()
+
+	sf, e := NewSyslogFormat(SyslogOptions{Addr: pc.LocalAddr().String(), AppName: "app"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer sf.Close()
+
+	NewLogger(sf).Info("hello")
+
+	buf := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, e := pc.ReadFrom(buf)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if s := string(buf[:n]); !strings.HasPrefix(s, "<14>1 ") || !strings.HasSuffix(s, "] hello") {
+		t.Fatalf("datagram: %q", s)
+	}
+}
+
+func TestSyslogTCPSpool(t *testing.T) {
+	// 先占用端口再关闭, 收集器启动前的日志保留在缓存中
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	sf, e := NewSyslogFormat(SyslogOptions{
+		Network:    "tcp",
+		Addr:       addr,
+		Spool:      3,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer sf.Close()
+
+	logger := NewLogger(sf)
+	for i := 0; i < 5; i++ {
+		logger.Warn("message %d", i)
+	}
+
+	if sf.Dropped() != 2 {
+		t.Fatalf("dropped: %d", sf.Dropped())
+	}
+
+	if l, e = net.Listen("tcp", addr); e != nil {
+		t.Skip("collector port reused: ", e)
+	}
+	defer l.Close()
+
+	conn, e := l.Accept()
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer conn.Close()
+
+	if e := sf.Flush(); e != nil {
+		t.Fatal(e)
+	}
+
+	r := bufio.NewReader(conn)
+	for i := 2; i < 5; i++ {
+		size, e := r.ReadString(' ')
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		n, _ := strconv.Atoi(strings.TrimSpace(size))
+		msg := make([]byte, n)
+		if _, e := io.ReadFull(r, msg); e != nil {
+			t.Fatal(e)
+		}
+
+		if !strings.HasPrefix(string(msg), "<12>1 ") || !strings.HasSuffix(string(msg), "message "+strconv.Itoa(i)) {
+			t.Fatalf("message %d: %q", i, msg)
+		}
+	}
+}
+
+func TestSyslogFlushDropOldest(t *testing.T) {
+	pc, e := net.ListenPacket("udp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer pc.Close()
+
+	ready := &atomic.Bool{}
+	sf, e := NewSyslogFormat(SyslogOptions{
+		Spool:      1,
+		MinBackoff: 5 * time.Millisecond,
+		MaxBackoff: 10 * time.Millisecond,
+		Dial: func() (net.Conn, error) {
+			if !ready.Load() {
+				return nil, errors.New("collector down")
+			}
+
+			return net.Dial("udp", pc.LocalAddr().String())
+		},
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer sf.Close()
+
+	logger := NewLogger(sf)
+	logger.Warn("first")
+
+	// 等待第一条消息的 Flush 不能因为丢弃最旧的消息而挂起
+	sf.lock.Lock()
+	drained := sf.drained
+	sf.lock.Unlock()
+
+	logger.Warn("second")
+	ready.Store(true)
+
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("flush waiter not released")
+	}
+
+	if sf.Dropped() != 1 {
+		t.Fatalf("dropped: %d", sf.Dropped())
+	}
+}
+
+// 拒绝过长消息的连接, 类似数据报的 EMSGSIZE
+type limitConn struct {
+	net.Conn
+	limit int
+	sent  chan string
+}
+
+func (c *limitConn) Write(b []byte) (int, error) {
+	if len(b) > c.limit {
+		return 0, errors.New("message too long")
+	}
+
+	c.sent <- string(b)
+	return len(b), nil
+}
+
+func (c *limitConn) SetWriteDeadline(t time.Time) error { return nil }
+func (c *limitConn) Close() error                       { return nil }
+
+func TestSyslogUnsendable(t *testing.T) {
+	sent := make(chan string, 8)
+	sf, e := NewSyslogFormat(SyslogOptions{
+		Network:     "udp",
+		MaxDatagram: 200,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  2 * time.Millisecond,
+		Dial: func() (net.Conn, error) {
+			return &limitConn{limit: 150, sent: sent}, nil
+		},
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer sf.Close()
+
+	logger := NewLogger(sf)
+	logger.Warn(strings.Repeat("界", 100))
+	logger.Warn("next")
+
+	if e := sf.Flush(); e != nil {
+		t.Fatal(e)
+	}
+
+	if sf.Dropped() != 1 || len(sent) != 1 || !strings.HasSuffix(<-sent, "] next") {
+		t.Fatalf("dropped: %d", sf.Dropped())
+	}
+
+	if g := truncateUTF8([]byte("a"+strings.Repeat("界", 100)), 200); len(g) != 199 || !utf8.Valid(g) {
+		t.Fatalf("truncate: %q", g)
+	}
+}
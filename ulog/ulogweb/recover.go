@@ -0,0 +1,36 @@
+package ulogweb
+
+import (
+	"net/http"
+
+	"uw/ulog"
+	"uw/uweb"
+)
+
+// panic 恢复中间件, 记录带有调用栈的错误日志并返回 500
+// @description 使用 uweb.Use 注册在 RequestID 之后, 其它中间件之前; End 和 Close 等流程控制不受影响
+// @param l 日志, 为空时使用请求上下文中的日志
+func Recover(l *ulog.Logger) uweb.HandlerFunc {
+	return func(c *uweb.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+
+			logger := l
+			if logger == nil {
+				logger = ulog.FromContext(c.Req.Context())
+			}
+
+			logger.LogPanic(r)
+
+			// panic 信息只记录在日志中, 不返回给客户端, 可以通过 RequestIDHeader 关联日志
+			c.Clean()
+			http.Error(c.Writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			c.End()
+		}()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,39 @@
+package ulogweb
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"uw/ulog"
+	"uw/uweb"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// 请求编号中间件
+// @description 使用请求头 X-Request-ID 或生成随机编号, 写入响应头, 并将带有 request_id 字段的日志放入请求上下文,
+// 之后的处理函数使用 ulog.FromContext(c.Req.Context()) 获取
+// @param l 基础日志, 为空时使用请求上下文中的日志
+func RequestID(l *ulog.Logger) uweb.HandlerFunc {
+	return func(c *uweb.Context) {
+		id := c.Req.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+
+		base := l
+		if base == nil {
+			base = ulog.FromContext(c.Req.Context())
+		}
+
+		c.SetHeader(RequestIDHeader, id)
+		c.Req = c.Req.WithContext(ulog.WithContext(c.Req.Context(), base.With("request_id", id)))
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,153 @@
+package ulogweb
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"uw/ulog"
+	"uw/uweb"
+)
+
+// 转发日志给 tail 连接, 只在有连接时注册到日志
+type tailFormat struct {
+	logger   *ulog.Logger
+	register sync.Mutex // 保证注册和删除的顺序, Write 不使用, 避免与日志的锁形成死锁
+	lock     sync.RWMutex
+	subs     map[*subscriber]struct{}
+	count    atomic.Int32
+}
+
+type subscriber struct {
+	logs    chan *ulog.Log
+	level   ulog.Level // 等级过滤, LevelFatal 总是转发
+	dropped atomic.Uint64
+}
+
+func (tf *tailFormat) Write(log *ulog.Log) {
+	if tf.count.Load() < 1 {
+		return
+	}
+
+	tf.lock.RLock()
+	defer tf.lock.RUnlock()
+
+	for sub := range tf.subs {
+		if sub.level&log.Level == 0 && log.Level != ulog.LevelFatal {
+			continue
+		}
+
+		select {
+		case sub.logs <- log.Clone():
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+func (tf *tailFormat) subscribe(level ulog.Level, buffer int) (*subscriber, func()) {
+	sub := &subscriber{logs: make(chan *ulog.Log, buffer), level: level}
+
+	tf.register.Lock()
+	defer tf.register.Unlock()
+
+	tf.lock.Lock()
+	tf.subs[sub] = struct{}{}
+	tf.lock.Unlock()
+
+	if tf.count.Add(1) == 1 {
+		tf.logger.Register(tf)
+	}
+
+	return sub, func() {
+		tf.register.Lock()
+		defer tf.register.Unlock()
+
+		tf.lock.Lock()
+		delete(tf.subs, sub)
+		tf.lock.Unlock()
+
+		if tf.count.Add(-1) == 0 {
+			tf.logger.UnregisterFormat(tf)
+		}
+	}
+}
+
+// 实时日志, 使用分块响应输出, 直到客户端断开
+// @description 参数 level 为逗号分隔的等级名称, 默认所有等级; q 为消息和字段中的子字符串;
+// format 为 text (默认) 或 json
+func (h *Handler) tailLogs(c *uweb.Context) {
+	level := ulog.DefaultLevel
+	if s := c.Query("level"); s != "" {
+		if e := level.UnmarshalText([]byte(s)); e != nil {
+			writeError(c, http.StatusBadRequest, e)
+			return
+		}
+	}
+
+	render, contentType := textRender(), "text/plain; charset=utf-8"
+	switch c.Query("format") {
+	case "", "text":
+	case "json":
+		jf := ulog.NewJSONFormat(nil)
+		render, contentType = jf.Format, "application/x-ndjson"
+	default:
+		writeError(c, http.StatusBadRequest, fmt.Errorf("unknown format: %s", c.Query("format")))
+		return
+	}
+
+	query := c.Query("q")
+	sub, cancel := h.tail.subscribe(level, h.opts.Buffer)
+	defer cancel()
+
+	ctx := c.Req.Context()
+	c.ResponseWriter(func(w http.ResponseWriter) {
+		w.Header().Set(uweb.HeaderContentType, contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		flusher, _ := w.(http.Flusher)
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		var reported uint64
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case log := <-sub.logs:
+				if dropped := sub.dropped.Load(); dropped != reported {
+					fmt.Fprintf(w, "ulogweb: %d logs dropped\n", dropped-reported)
+					reported = dropped
+				}
+
+				line := render(log)
+				if query != "" && !strings.Contains(ulog.CleanANSI(line), query) {
+					continue
+				}
+
+				if _, e := w.Write([]byte(line)); e != nil {
+					return
+				}
+
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+		}
+	})
+}
+
+// 无颜色的单行文本
+func textRender() func(log *ulog.Log) string {
+	df := ulog.NewDefaultFormat(nil)
+	df.SetLevel(ulog.DefaultLevelNoColor)
+
+	return func(log *ulog.Log) string {
+		return strings.TrimSuffix(df.PureFormat(log), "\r\n") + "\n"
+	}
+}
@@ -0,0 +1,316 @@
+package ulogweb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"uw/ulog"
+	"uw/uweb"
+)
+
+// 可以修改等级的格式化接口, 例如 *ulog.DefaultFormat 和 *ulog.JSONFormat
+type LevelFormat interface {
+	ulog.Format
+	GetLevel() ulog.Level
+	SetLevel(val ulog.Level)
+}
+
+type Options struct {
+	Logger *ulog.Logger // 管理的日志, 默认 ulog.GlobalLogger()
+	Buffer int          // 每个 tail 连接的缓冲日志数量, 缓冲满时丢弃, 默认 256
+}
+
+// 日志管理接口
+// @description 挂载到 uweb.Group 后提供:
+//
+//	GET /formats          格式化接口列表和等级
+//	PUT /formats/:id      修改等级, 可以设置 ttl 在一段时间后恢复
+//	GET /modules          模块等级, 参考 ulog.SetModuleLevel
+//	PUT /modules          修改模块等级, 值为空字符串时删除
+//	GET /tail             实时日志, 参数 level (等级列表), q (子字符串), format (text/json)
+type Handler struct {
+	opts    Options
+	tail    *tailFormat
+	lock    sync.Mutex
+	ids     map[ulog.Format]int // 格式化接口的编号, 删除其它格式化接口后不变
+	nextID  int
+	reverts map[int]*revert // 格式化接口的等级恢复
+}
+
+type revert struct {
+	timer    *time.Timer
+	at       time.Time
+	previous ulog.Level // 恢复的等级
+}
+
+type FormatInfo struct {
+	ID     int      `json:"id"`     // 编号, 按第一次出现的顺序分配, 不会因为删除其它格式化接口而改变
+	Type   string   `json:"type"`   // 类型名称
+	Level  uint8    `json:"level"`  // 等级位掩码, 不支持修改等级时为 0
+	Levels []string `json:"levels"` // 等级名称
+	Revert string   `json:"revert"` // 等级恢复的时间 (RFC3339), 没有时为空
+}
+
+type FormatUpdate struct {
+	Level   []string `json:"level"`   // 替换的等级名称
+	Enable  []string `json:"enable"`  // 添加的等级名称, 例如 color
+	Disable []string `json:"disable"` // 删除的等级名称
+	TTL     string   `json:"ttl"`     // 修改的有效时长, 例如 10m, 到期后恢复原等级
+}
+
+func New(opts Options) *Handler {
+	if opts.Logger == nil {
+		opts.Logger = ulog.GlobalLogger()
+	}
+
+	if opts.Buffer < 1 {
+		opts.Buffer = 256
+	}
+
+	return &Handler{
+		opts:    opts,
+		tail:    &tailFormat{logger: opts.Logger, subs: map[*subscriber]struct{}{}},
+		ids:     map[ulog.Format]int{},
+		reverts: map[int]*revert{},
+	}
+}
+
+// 挂载到路由组
+func (h *Handler) Mount(g *uweb.Group) {
+	g.Get("/formats", h.listFormats)
+	g.Put("/formats/:id", h.updateFormat)
+	g.Get("/modules", h.listModules)
+	g.Put("/modules", h.updateModules)
+	g.Get("/tail", h.tailLogs)
+}
+
+// 格式化接口和编号, 不包含 tail 使用的格式化接口
+type formatEntry struct {
+	id     int
+	format ulog.Format
+}
+
+// 格式化接口列表, 为新的格式化接口分配编号, 删除已经不存在的编号
+func (h *Handler) formats() []formatEntry {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	list, ids := []formatEntry{}, map[ulog.Format]int{}
+	for _, f := range h.opts.Logger.Formats() {
+		if f == ulog.Format(h.tail) {
+			continue
+		}
+
+		id, ok := h.ids[f]
+		if !ok {
+			id = h.nextID
+			h.nextID++
+		}
+
+		ids[f] = id
+		list = append(list, formatEntry{id: id, format: f})
+	}
+
+	h.ids = ids
+	return list
+}
+
+func (h *Handler) formatInfo(id int, f ulog.Format) FormatInfo {
+	info := FormatInfo{ID: id, Type: reflect.TypeOf(f).String(), Levels: []string{}}
+	if lf, ok := f.(LevelFormat); ok {
+		info.Level = uint8(lf.GetLevel())
+		info.Levels = ulog.LevelNames(lf.GetLevel())
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if r, ok := h.reverts[id]; ok {
+		info.Revert = r.at.Format(time.RFC3339)
+	}
+
+	return info
+}
+
+func (h *Handler) listFormats(c *uweb.Context) {
+	list := []FormatInfo{}
+	for _, entry := range h.formats() {
+		list = append(list, h.formatInfo(entry.id, entry.format))
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *Handler) updateFormat(c *uweb.Context) {
+	var format ulog.Format
+	id, e := strconv.Atoi(c.Param("id"))
+	if e == nil {
+		for _, entry := range h.formats() {
+			if entry.id == id {
+				format = entry.format
+				break
+			}
+		}
+	}
+
+	if format == nil {
+		writeError(c, http.StatusNotFound, fmt.Errorf("format not found: %s", c.Param("id")))
+		return
+	}
+
+	lf, ok := format.(LevelFormat)
+	if !ok {
+		writeError(c, http.StatusBadRequest, fmt.Errorf("format %d does not support levels", id))
+		return
+	}
+
+	update := FormatUpdate{}
+	if e := json.Unmarshal(c.ReqBody(), &update); e != nil {
+		writeError(c, http.StatusBadRequest, e)
+		return
+	}
+
+	previous := lf.GetLevel()
+	level, e := applyUpdate(previous, update)
+	if e != nil {
+		writeError(c, http.StatusBadRequest, e)
+		return
+	}
+
+	var ttl time.Duration
+	if update.TTL != "" {
+		if ttl, e = time.ParseDuration(update.TTL); e != nil || ttl <= 0 {
+			writeError(c, http.StatusBadRequest, fmt.Errorf("invalid ttl: %s", update.TTL))
+			return
+		}
+	}
+
+	lf.SetLevel(level)
+	h.scheduleRevert(id, lf, previous, level, ttl)
+
+	c.JSON(http.StatusOK, h.formatInfo(id, lf))
+}
+
+func applyUpdate(level ulog.Level, update FormatUpdate) (ulog.Level, error) {
+	parse := func(names []string) (ulog.Level, error) {
+		var level ulog.Level
+		for _, name := range names {
+			l, e := ulog.ParseLevel(name)
+			if e != nil {
+				return 0, e
+			}
+
+			level |= l
+		}
+
+		return level, nil
+	}
+
+	if update.Level != nil {
+		l, e := parse(update.Level)
+		if e != nil {
+			return 0, e
+		}
+
+		level = l
+	}
+
+	enable, e := parse(update.Enable)
+	if e != nil {
+		return 0, e
+	}
+
+	disable, e := parse(update.Disable)
+	if e != nil {
+		return 0, e
+	}
+
+	return (level | enable) &^ disable, nil
+}
+
+// 到期后恢复等级, 期间等级被其它请求修改时不恢复
+// @description 连续的限时修改恢复到第一次修改之前的等级, 不限时的修改取消恢复
+func (h *Handler) scheduleRevert(id int, lf LevelFormat, previous, level ulog.Level, ttl time.Duration) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if r, ok := h.reverts[id]; ok {
+		r.timer.Stop()
+		previous = r.previous
+		delete(h.reverts, id)
+	}
+
+	if ttl <= 0 {
+		return
+	}
+
+	r := &revert{at: time.Now().Add(ttl), previous: previous}
+	r.timer = time.AfterFunc(ttl, func() {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+
+		if h.reverts[id] != r {
+			return
+		}
+
+		delete(h.reverts, id)
+		if lf.GetLevel() == level {
+			lf.SetLevel(previous)
+		}
+	})
+
+	h.reverts[id] = r
+}
+
+func (h *Handler) listModules(c *uweb.Context) {
+	levels := map[string]string{}
+	for name, level := range ulog.ModuleLevels() {
+		levels[name] = strings.ToLower(ulog.LevelName(level))
+	}
+
+	c.JSON(http.StatusOK, levels)
+}
+
+func (h *Handler) updateModules(c *uweb.Context) {
+	update := map[string]string{}
+	if e := json.Unmarshal(c.ReqBody(), &update); e != nil {
+		writeError(c, http.StatusBadRequest, e)
+		return
+	}
+
+	levels := map[string]ulog.Level{}
+	for name, value := range update {
+		if value == "" {
+			continue
+		}
+
+		level, e := ulog.ParseLevel(value)
+		if e != nil {
+			writeError(c, http.StatusBadRequest, fmt.Errorf("module %q: %w", name, e))
+			return
+		}
+
+		levels[name] = level
+	}
+
+	for name, value := range update {
+		if value == "" {
+			ulog.UnsetModuleLevel(name)
+			continue
+		}
+
+		ulog.SetModuleLevel(name, levels[name])
+	}
+
+	h.listModules(c)
+}
+
+func writeError(c *uweb.Context, code int, e error) {
+	c.JSON(code, map[string]string{"error": e.Error()})
+}
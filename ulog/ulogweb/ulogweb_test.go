@@ -0,0 +1,245 @@
+package ulogweb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"uw/ulog"
+	"uw/uweb"
+)
+
+func newServer(t *testing.T) (*ulog.Logger, *ulog.DefaultFormat, *httptest.Server) {
+	df := ulog.NewDefaultFormat(func(s string) {})
+	l := ulog.NewLogger(df)
+
+	u := uweb.New()
+	New(Options{Logger: l}).Mount(u.NewGroup("/admin/log"))
+
+	ts := httptest.NewServer(u)
+	t.Cleanup(ts.Close)
+
+	return l, df, ts
+}
+
+func request(t *testing.T, method, url, body string, v interface{}) int {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	resp, e := http.DefaultClient.Do(req)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer resp.Body.Close()
+
+	if e := json.NewDecoder(resp.Body).Decode(v); e != nil {
+		t.Fatal(e)
+	}
+
+	return resp.StatusCode
+}
+
+func TestFormats(t *testing.T) {
+	l, df, ts := newServer(t)
+
+	list := []FormatInfo{}
+	if request(t, "GET", ts.URL+"/admin/log/formats", "", &list) != 200 || len(list) != 1 {
+		t.Fatalf("formats: %+v", list)
+	}
+
+	if list[0].Type != "*ulog.DefaultFormat" || list[0].Level != uint8(ulog.DefaultLevel) {
+		t.Fatalf("format: %+v", list[0])
+	}
+
+	info := FormatInfo{}
+	code := request(t, "PUT", ts.URL+"/admin/log/formats/0",
+		`{"level":["info","warn","error"],"enable":["debug"],"ttl":"50ms"}`, &info)
+	if code != 200 || df.GetLevel() != ulog.LevelDebug|ulog.LevelInfo|ulog.LevelWarn|ulog.LevelError ||
+		info.Revert == "" {
+		t.Fatalf("update: %d %+v", code, info)
+	}
+
+	code = request(t, "PUT", ts.URL+"/admin/log/formats/0", `{"disable":["color"],"ttl":"50ms"}`, &info)
+	if code != 200 || strings.Join(info.Levels, " ") != "debug info warn error" {
+		t.Fatalf("disable color: %d %+v", code, info)
+	}
+
+	// 恢复到第一次修改之前的等级
+	deadline := time.Now().Add(5 * time.Second)
+	for df.GetLevel() != ulog.DefaultLevel {
+		if time.Now().After(deadline) {
+			t.Fatalf("level not reverted: %v", ulog.LevelNames(df.GetLevel()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	errResp := map[string]string{}
+	if request(t, "PUT", ts.URL+"/admin/log/formats/0", `{"level":["loud"]}`, &errResp) != 400 ||
+		request(t, "PUT", ts.URL+"/admin/log/formats/9", `{}`, &errResp) != 404 {
+		t.Fatalf("errors: %v", errResp)
+	}
+
+	// 删除格式化接口后其它格式化接口的编号不变
+	l.Register(ulog.NewJSONFormat(func(s string) {}))
+	request(t, "GET", ts.URL+"/admin/log/formats", "", &list)
+	l.UnregisterFormat(df)
+
+	list = []FormatInfo{}
+	if request(t, "GET", ts.URL+"/admin/log/formats", "", &list) != 200 || len(list) != 1 ||
+		list[0].ID != 1 || list[0].Type != "*ulog.JSONFormat" {
+		t.Fatalf("stable ids: %+v", list)
+	}
+
+	if request(t, "PUT", ts.URL+"/admin/log/formats/0", `{}`, &errResp) != 404 ||
+		request(t, "PUT", ts.URL+"/admin/log/formats/1", `{"level":["error"]}`, &info) != 200 || info.ID != 1 {
+		t.Fatalf("update by id: %+v", info)
+	}
+}
+
+func TestModules(t *testing.T) {
+	_, _, ts := newServer(t)
+	t.Cleanup(func() { ulog.SetModuleLevels(nil) })
+
+	levels := map[string]string{}
+	if request(t, "PUT", ts.URL+"/admin/log/modules", `{"upg":"warn","uweb":"debug"}`, &levels) != 200 ||
+		levels["upg"] != "warn" || levels["uweb"] != "debug" {
+		t.Fatalf("modules: %v", levels)
+	}
+
+	levels = map[string]string{}
+	if request(t, "PUT", ts.URL+"/admin/log/modules", `{"upg":""}`, &levels) != 200 || len(levels) != 1 {
+		t.Fatalf("unset: %v", levels)
+	}
+}
+
+func TestTail(t *testing.T) {
+	l, _, ts := newServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/admin/log/tail?level=warn,error&q=disk", nil)
+	resp, e := http.DefaultClient.Do(req)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("status: %d", resp.StatusCode)
+	}
+
+	l.Warn("cpu hot")
+	l.Info("disk info")
+	l.Warn("disk almost full", "free", "1G")
+
+	line, e := bufio.NewReader(resp.Body).ReadString('\n')
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if !strings.HasPrefix(line, "WARN ") || !strings.HasSuffix(line, "disk almost full free=1G\n") {
+		t.Fatalf("line: %q", line)
+	}
+
+	// 客户端断开后删除 tail 的格式化接口
+	cancel()
+	for deadline := time.Now().Add(5 * time.Second); len(l.Formats()) != 1; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("tail format not unregistered: %d", len(l.Formats()))
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	df := ulog.NewDefaultFormat(nil)
+	lines := make(chan string, 4)
+	df.SetWriter(func(s string) { lines <- s })
+	df.SetLevel(ulog.DefaultLevelNoColor)
+
+	u := uweb.New()
+	u.Use(RequestID(ulog.NewLogger(df)))
+	u.Get("/hello", func(c *uweb.Context) {
+		ulog.FromContext(c.Req.Context()).Info("hello")
+		c.String(http.StatusOK, "ok")
+	})
+
+	ts := httptest.NewServer(u)
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/hello", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	resp, e := http.DefaultClient.Do(req)
+	if e != nil {
+		t.Fatal(e)
+	}
+	resp.Body.Close()
+
+	if resp.Header.Get(RequestIDHeader) != "abc" {
+		t.Fatalf("header: %v", resp.Header)
+	}
+
+	if line := <-lines; !strings.HasSuffix(line, "hello request_id=abc\r\n") {
+		t.Fatalf("line: %q", line)
+	}
+
+	resp, e = http.Get(ts.URL + "/hello")
+	if e != nil {
+		t.Fatal(e)
+	}
+	resp.Body.Close()
+
+	if id := resp.Header.Get(RequestIDHeader); len(id) != 16 || !strings.Contains(<-lines, "request_id="+id) {
+		t.Fatalf("generated id: %q", id)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	lines := []string{}
+	df := ulog.NewDefaultFormat(func(s string) { lines = append(lines, s) })
+	df.SetLevel(ulog.DefaultLevelNoColor)
+
+	u := uweb.New()
+	u.Use(Recover(ulog.NewLogger(df)))
+	u.Get("/panic", func(c *uweb.Context) {
+		panic("nil map")
+	})
+	u.Get("/end", func(c *uweb.Context) {
+		c.String(http.StatusOK, "ok")
+		c.End()
+	})
+
+	ts := httptest.NewServer(u)
+	defer ts.Close()
+
+	resp, e := http.Get(ts.URL + "/panic")
+	if e != nil {
+		t.Fatal(e)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// 响应中不包含 panic 信息
+	if s := strings.TrimSpace(string(body)); s != http.StatusText(http.StatusInternalServerError) {
+		t.Fatalf("body: %q", s)
+	}
+
+	if resp.StatusCode != http.StatusInternalServerError || len(lines) != 1 ||
+		!strings.Contains(lines[0], "panic: nil map") || !strings.Contains(lines[0], "ulogweb.TestRecover.func") {
+		t.Fatalf("panic: %d %q", resp.StatusCode, lines)
+	}
+
+	resp, e = http.Get(ts.URL + "/end")
+	if e != nil {
+		t.Fatal(e)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || len(lines) != 1 {
+		t.Fatalf("end: %d %q", resp.StatusCode, lines)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"uw/upg"
@@ -76,7 +77,8 @@ type QueryHook struct {
 	verbose   bool
 	emptyLine bool
 	color     bool
-	log       Logger
+	log       Logger // 为空时使用上下文中的日志, 参考 ulog.FromContext
+	queryID   atomic.Uint64
 }
 
 var _ upg.QueryHook = (*QueryHook)(nil)
@@ -85,7 +87,6 @@ func NewQueryHook(opts ...Option) *QueryHook {
 	h := &QueryHook{
 		enabled: true,
 		color:   true,
-		log:     ulog.GlobalLogger(),
 	}
 	for _, opt := range opts {
 		opt(h)
@@ -93,10 +94,23 @@ func NewQueryHook(opts ...Option) *QueryHook {
 	return h
 }
 
+// 为上下文中的日志添加 query_id 字段, 查询期间使用该上下文的日志都带有查询编号
 func (h *QueryHook) BeforeQuery(
 	ctx context.Context, event *upg.QueryEvent,
 ) (context.Context, error) {
-	return ctx, nil
+	if !h.enabled {
+		return ctx, nil
+	}
+
+	return ulog.ContextWith(ctx, "query_id", h.queryID.Add(1)), nil
+}
+
+func (h *QueryHook) logger(ctx context.Context) Logger {
+	if h.log != nil {
+		return h.log
+	}
+
+	return ulog.FromContext(ctx)
 }
 
 func (h *QueryHook) emptyLineParse() string {
@@ -119,20 +133,21 @@ func (h *QueryHook) AfterQuery(ctx context.Context, evt *upg.QueryEvent) error {
 		}
 	}
 
+	log := h.logger(ctx)
 	if evt.Err != nil {
-		h.log.Printf("[upg] [%s] [%s] %s\r\n"+h.emptyLineParse(),
+		log.Printf("[upg] [%s] [%s] %s\r\n"+h.emptyLineParse(),
 			time.Since(evt.StartTime), h.ansiCode(ulog.ANSI.Red, evt.Err.Error()), evt.Query)
 		return nil
 	}
 
 	if evt.Result != nil {
-		h.log.Printf("[upg] [%s] (%d/%d) %s"+h.emptyLineParse(),
+		log.Printf("[upg] [%s] (%d/%d) %s"+h.emptyLineParse(),
 			time.Since(evt.StartTime), evt.Result.RowsAffected(),
 			evt.Result.RowsReturned(), evt.Query)
 		return nil
 	}
 
-	h.log.Printf("[upg] [%s] %s"+h.emptyLineParse(),
+	log.Printf("[upg] [%s] %s"+h.emptyLineParse(),
 		time.Since(evt.StartTime), evt.Query)
 	return nil
 }
@@ -3,6 +3,8 @@ package urest
 import (
 	"net/http"
 	"sync"
+
+	"uw/ulog"
 )
 
 var contextPool = sync.Pool{
@@ -42,6 +44,11 @@ func (c *Context) release() {
 	contextPool.Put(c)
 }
 
+// 请求上下文中的日志, 参考 ulog.FromContext
+func (c *Context) Logger() *ulog.Logger {
+	return ulog.FromContext(c.Req.Context())
+}
+
 func (c *Context) Set(key string, value interface{}) {
 	c.ctxStoreLock.Lock()
 	defer c.ctxStoreLock.Unlock()
@@ -80,11 +87,13 @@ func (c *Context) Index() (int, int) {
 	return len(c.m.handlerList), c.index
 }
 
+// 结束处理流程, 已经写入的响应正常返回
 func (c *Context) End() {
 	c.index = -10
 	panic(nil)
 }
 
+// 结束处理流程并中断连接
 func (c *Context) Close() {
 	c.index = -100
 	panic(nil)
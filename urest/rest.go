@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"path"
+	"runtime"
 	"strings"
 
-	"uw/ulog"
 	"uw/uweb"
 )
 
@@ -144,12 +144,22 @@ func (rt *Restot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	c.Writer = w
 
 	defer func() {
-		if r := recover(); r != nil {
+		r := recover()
+
+		// End 和 Close 使用 panic(nil) 结束处理流程, 不是真正的 panic
+		if _, ok := r.(*runtime.PanicNilError); ok || r == nil {
+			switch c.index {
+			case -10:
+				return
+			case -100:
+				panic(http.ErrAbortHandler)
+			}
+		}
+
+		if r != nil {
+			c.Logger().LogPanic(r)
 			c.Writer.WriteHeader(http.StatusInternalServerError)
 			fmt.Fprintf(c.Writer, "InternalServerError: %v", r)
-			ulog.Error("Internal Server Error: %v", r)
-		} else if c.index == -100 {
-			panic(nil)
 		}
 	}()
 
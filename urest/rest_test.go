@@ -0,0 +1,84 @@
+package urest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"uw/ulog"
+)
+
+func serveRestot(t *testing.T, handler Handler) (*httptest.Server, *[]string) {
+	lines := []string{}
+	df := ulog.NewDefaultFormat(func(s string) { lines = append(lines, s) })
+	df.SetLevel(ulog.DefaultLevelNoColor)
+	l := ulog.NewLogger(df)
+
+	rt := &Restot{handlerList: []Handler{handler}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt.ServeHTTP(w, r.WithContext(ulog.WithContext(r.Context(), l)))
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, &lines
+}
+
+func get(t *testing.T, url string) (int, string) {
+	resp, e := http.Get(url)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestServeHTTPEnd(t *testing.T) {
+	ts, lines := serveRestot(t, func(c *Context) {
+		c.Writer.Write([]byte("ok"))
+		c.End()
+	})
+
+	if code, body := get(t, ts.URL); code != http.StatusOK || body != "ok" || len(*lines) != 0 {
+		t.Fatalf("end: %d %q %q", code, body, *lines)
+	}
+}
+
+func TestServeHTTPDefaultRecover(t *testing.T) {
+	ts, lines := serveRestot(t, func(c *Context) {
+		DefaultRecover(c, errors.New("bad input"))
+	})
+
+	code, body := get(t, ts.URL)
+	if code != http.StatusInternalServerError || body != "Internal Server Error: bad input" ||
+		len(*lines) != 1 || strings.Contains((*lines)[0], "panic") {
+		t.Fatalf("recover: %d %q %q", code, body, *lines)
+	}
+}
+
+func TestServeHTTPPanic(t *testing.T) {
+	ts, lines := serveRestot(t, func(c *Context) {
+		panic("nil map")
+	})
+
+	code, body := get(t, ts.URL)
+	if code != http.StatusInternalServerError || body != "InternalServerError: nil map" ||
+		len(*lines) != 1 || !strings.Contains((*lines)[0], "panic: nil map") ||
+		!strings.Contains((*lines)[0], "TestServeHTTPPanic") {
+		t.Fatalf("panic: %d %q %q", code, body, *lines)
+	}
+}
+
+func TestServeHTTPClose(t *testing.T) {
+	ts, _ := serveRestot(t, func(c *Context) {
+		c.Close()
+	})
+
+	if _, e := http.Get(ts.URL); e == nil {
+		t.Fatal("connection not aborted")
+	}
+}
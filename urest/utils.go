@@ -10,7 +10,6 @@ import (
 
 	"uw/pkg/cast"
 	"uw/pkg/tagparser"
-	"uw/ulog"
 )
 
 var (
@@ -231,6 +230,6 @@ func DefaultResponse(w http.ResponseWriter, resp interface{}) {
 func DefaultRecover(c *Context, e error) {
 	c.Writer.WriteHeader(http.StatusInternalServerError)
 	fmt.Fprintf(c.Writer, "Internal Server Error: %v", e)
-	ulog.Error("Internal Server Error: %v", e)
+	c.Logger().Error("Internal Server Error: %v", e)
 	c.End()
 }